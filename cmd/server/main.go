@@ -3,8 +3,11 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
 	"strings" // Нужен для HasPrefix
+	"time"
 
+	adminpanel "lingo-sprint/internal/admin"
 	"lingo-sprint/internal/api"
 	"lingo-sprint/internal/database"
 
@@ -37,17 +40,80 @@ func main() {
 
 	// --- 1. РЕГИСТРАЦИЯ API ЭНДПОИНТОВ ---
 	apiRouter := r.PathPrefix("/api").Subrouter()
-	apiRouter.HandleFunc("/register", apiHandler.RegisterUser).Methods("POST")
-	apiRouter.HandleFunc("/login", apiHandler.LoginUser).Methods("POST")
-
+	// Публичные auth-эндпоинты защищены от перебора и спама (429 + Retry-After)
+	apiRouter.Handle("/register", apiHandler.Throttle(api.ActionRegister)(http.HandlerFunc(apiHandler.RegisterUser))).Methods("POST")
+	apiRouter.Handle("/login", apiHandler.Throttle(api.ActionLogin)(http.HandlerFunc(apiHandler.LoginUser))).Methods("POST")
+	apiRouter.Handle("/login/2fa", apiHandler.Throttle(api.ActionLogin2FA)(http.HandlerFunc(apiHandler.LoginTOTP))).Methods("POST")
+	apiRouter.HandleFunc("/verify-email", apiHandler.VerifyEmail).Methods("GET")
+	apiRouter.HandleFunc("/me/email/confirm", apiHandler.ConfirmEmailChange).Methods("GET")
+	apiRouter.HandleFunc("/auth/providers", apiHandler.ListAuthProviders).Methods("GET")
+	apiRouter.HandleFunc("/auth/oidc/{provider}/start", apiHandler.StartOIDCLogin).Methods("GET")
+	apiRouter.HandleFunc("/auth/oidc/{provider}/callback", apiHandler.OIDCCallback).Methods("GET")
+	apiRouter.Handle("/guest", apiHandler.Throttle(api.ActionGuest)(http.HandlerFunc(apiHandler.StartGuestSession))).Methods("POST")
+	apiRouter.Handle("/verify-email/resend", apiHandler.Throttle(api.ActionResendEmail)(http.HandlerFunc(apiHandler.ResendVerification))).Methods("POST")
+	// Версия контента: клиенты сверяют ее, чтобы понять, что уроки обновились
+	apiRouter.HandleFunc("/content/version", apiHandler.GetContentVersion).Methods("GET")
+
+	// Эндпоинты, доступные и по персональным токенам (PAT) с нужными scope, и по обычной сессии.
+	// Для курсов, уровней, уроков и предложений достаточно read-content; прогресс в их ответах
+	// (пройденные уроки, звезды, статусы предложений) PAT без read-progress не получает.
+	readContent := apiHandler.TokenAuth(api.ScopeReadContent)
+	apiRouter.Handle("/courses", readContent(http.HandlerFunc(apiHandler.ListCourses))).Methods("GET")
+	apiRouter.Handle("/levels", readContent(http.HandlerFunc(apiHandler.GetLevels))).Methods("GET")
+	apiRouter.Handle("/levels/{level_id:[0-9]+}/lessons", readContent(http.HandlerFunc(apiHandler.GetLessonsByLevel))).Methods("GET")
+	apiRouter.Handle("/lessons/{lesson_id:[0-9]+}/sentences", readContent(http.HandlerFunc(apiHandler.GetSentencesByLesson))).Methods("GET")
+	apiRouter.Handle("/progress", apiHandler.TokenAuth(api.ScopeReadProgress)(http.HandlerFunc(apiHandler.GetProgress))).Methods("GET")
+	apiRouter.Handle("/progress/save", apiHandler.TokenAuth(api.ScopeWriteProgress)(http.HandlerFunc(apiHandler.SaveProgress))).Methods("POST")
+
+	// Остальное - только по сессии (JWT); PAT здесь не принимаются
 	s := apiRouter.PathPrefix("/").Subrouter()
-	s.Use(api.AuthMiddleware)
-	s.HandleFunc("/levels", apiHandler.GetLevels).Methods("GET")
-	s.HandleFunc("/levels/{level_id:[0-9]+}/lessons", apiHandler.GetLessonsByLevel).Methods("GET")
-	s.HandleFunc("/lessons/{lesson_id:[0-9]+}/sentences", apiHandler.GetSentencesByLesson).Methods("GET")
-	s.HandleFunc("/progress/save", apiHandler.SaveProgress).Methods("POST")
+	s.Use(apiHandler.AuthMiddleware)
 	// --- УБЕДИТЕСЬ, ЧТО ЭТО РАСКОММЕНТИРОВАНО ---
-	s.HandleFunc("/ai/explain-error", apiHandler.ExplainError).Methods("POST")
+	s.Handle("/ai/explain-error", api.DenyGuests(http.HandlerFunc(apiHandler.ExplainError))).Methods("POST")
+
+	// Аккаунт: гостям (роль "guest") недоступно, им сначала нужно зарегистрироваться
+	me := s.PathPrefix("/me").Subrouter()
+	me.Use(api.DenyGuests, api.DenyImpersonatedWrites)
+	me.HandleFunc("", apiHandler.GetMe).Methods("GET")
+	me.HandleFunc("", apiHandler.UpdateMe).Methods("PATCH")
+	me.HandleFunc("", apiHandler.DeleteMe).Methods("DELETE")
+	me.HandleFunc("/export", apiHandler.ExportMyData).Methods("GET")
+	me.HandleFunc("/password", apiHandler.ChangePassword).Methods("POST")
+	me.HandleFunc("/email", apiHandler.RequestEmailChange).Methods("POST")
+	me.HandleFunc("/merge-guest", apiHandler.MergeGuest).Methods("POST")
+	me.HandleFunc("/tokens", apiHandler.ListTokens).Methods("GET")
+	me.HandleFunc("/tokens", apiHandler.CreateToken).Methods("POST")
+	me.HandleFunc("/tokens/{token_id:[0-9]+}", apiHandler.RevokeToken).Methods("DELETE")
+	me.HandleFunc("/2fa/enroll", apiHandler.EnrollTOTP).Methods("POST")
+	me.HandleFunc("/2fa/confirm", apiHandler.ConfirmTOTP).Methods("POST")
+	me.HandleFunc("/2fa/disable", apiHandler.DisableTOTP).Methods("POST")
+
+	// --- Администрирование (права проверяются по роли из JWT) ---
+	admin := s.PathPrefix("/admin").Subrouter()
+	admin.Handle("/users/{user_id:[0-9]+}/role", api.RequirePermission(api.PermUsersManage)(http.HandlerFunc(apiHandler.SetUserRole))).Methods("PUT")
+	admin.Handle("/audit-events", api.RequirePermission(api.PermAuditRead)(http.HandlerFunc(apiHandler.QueryAuditEvents))).Methods("GET")
+	admin.Handle("/users/{user_id:[0-9]+}/impersonate", api.RequirePermission(api.PermImpersonate)(http.HandlerFunc(apiHandler.StartImpersonation))).Methods("POST")
+	// Одноразовый вход в админ-панель /admin
+	admin.Handle("/panel-token", api.RequireRole(api.RoleAdmin)(http.HandlerFunc(apiHandler.IssuePanelToken))).Methods("POST")
+
+	// Контент: уровни, уроки, предложения (правка текста обнуляет audio_path)
+	editContent := api.RequirePermission(api.PermContentEdit)
+	admin.Handle("/courses", editContent(http.HandlerFunc(apiHandler.CreateCourse))).Methods("POST")
+	admin.Handle("/courses/{course_id:[0-9]+}", editContent(http.HandlerFunc(apiHandler.UpdateCourse))).Methods("PUT")
+	admin.Handle("/levels", editContent(http.HandlerFunc(apiHandler.CreateLevel))).Methods("POST")
+	admin.Handle("/levels/{level_id:[0-9]+}", editContent(http.HandlerFunc(apiHandler.UpdateLevel))).Methods("PUT")
+	admin.Handle("/levels/{level_id:[0-9]+}", editContent(http.HandlerFunc(apiHandler.DeleteLevel))).Methods("DELETE")
+	admin.Handle("/levels/{level_id:[0-9]+}/lessons", editContent(http.HandlerFunc(apiHandler.ListLevelLessons))).Methods("GET")
+	admin.Handle("/levels/{level_id:[0-9]+}/lessons", editContent(http.HandlerFunc(apiHandler.CreateLesson))).Methods("POST")
+	admin.Handle("/lessons/{lesson_id:[0-9]+}", editContent(http.HandlerFunc(apiHandler.UpdateLesson))).Methods("PUT")
+	admin.Handle("/lessons/{lesson_id:[0-9]+}/status", api.RequirePermission(api.PermContentPublish)(http.HandlerFunc(apiHandler.UpdateLessonStatus))).Methods("PUT")
+	admin.Handle("/lessons/{lesson_id:[0-9]+}", editContent(http.HandlerFunc(apiHandler.DeleteLesson))).Methods("DELETE")
+	admin.Handle("/lessons/{lesson_id:[0-9]+}/sentences", editContent(http.HandlerFunc(apiHandler.ListLessonSentences))).Methods("GET")
+	admin.Handle("/lessons/{lesson_id:[0-9]+}/sentences", editContent(http.HandlerFunc(apiHandler.CreateSentence))).Methods("POST")
+	admin.Handle("/lessons/{lesson_id:[0-9]+}/sentences/order", editContent(http.HandlerFunc(apiHandler.ReorderSentences))).Methods("PUT")
+	admin.Handle("/sentences/{sentence_id:[0-9]+}", editContent(http.HandlerFunc(apiHandler.UpdateSentence))).Methods("PUT")
+	admin.Handle("/sentences/{sentence_id:[0-9]+}", editContent(http.HandlerFunc(apiHandler.DeleteSentence))).Methods("DELETE")
+	admin.Handle("/sentences/{sentence_id:[0-9]+}/revisions", editContent(http.HandlerFunc(apiHandler.ListSentenceRevisions))).Methods("GET")
 
 	// --- 2. РЕГИСТРАЦИЯ СТРАНИЦ ПРИЛОЖЕНИЯ ---
 	r.HandleFunc("/app", func(w http.ResponseWriter, r *http.Request) {
@@ -87,6 +153,11 @@ func main() {
 	// 	return isStatic
 	// }).Handler(http.StripPrefix("/", fs))
 
+	// --- АДМИН-ПАНЕЛЬ go-admin (/admin), до FILESERVER статики ---
+	if err := adminpanel.Mount(r, apiHandler, os.Getenv("DATABASE_URL")); err != nil {
+		log.Fatalf("Admin panel error: %v", err)
+	}
+
 	// --- 4. РЕГИСТРАЦИЯ FILESERVER ДЛЯ /media (НОВОЕ) ---
 	// Этот код будет обслуживать ваши MP3-файлы
 	mediaFs := http.FileServer(http.Dir("./media/"))
@@ -107,6 +178,23 @@ func main() {
 	}).Handler(http.StripPrefix("/", fs))
 
 
+	// --- ОКОНЧАТЕЛЬНОЕ УДАЛЕНИЕ АККАУНТОВ И СТАРЫХ ГОСТЕЙ (раз в час) ---
+	go func() {
+		for {
+			if n, err := apiHandler.PurgeDeletedAccounts(); err != nil {
+				log.Printf("Account purge error: %v", err)
+			} else if n > 0 {
+				log.Printf("Purged %d deleted accounts", n)
+			}
+			if n, err := apiHandler.PurgeExpiredGuests(); err != nil {
+				log.Printf("Guest purge error: %v", err)
+			} else if n > 0 {
+				log.Printf("Purged %d expired guests", n)
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+
 	// --- ЗАПУСК СЕРВЕРА ---
 	port := ":8080"
 	log.Printf("Starting server on port %s", port)
@@ -0,0 +1,228 @@
+// Package admin - админ-панель на go-admin (/admin): пользователи (роль, подписка),
+// уровни, уроки, предложения с прослушиванием аудио и прогресс учеников.
+// Вход - только для роли "admin" и только через сессию приложения (api.IssuePanelToken).
+// Все изменения идут через те же методы, что и REST API, поэтому проверки и журнал
+// audit_events у панели и API общие. Служебные таблицы go-admin создает scripts/goadmin.sql.
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	_ "github.com/GoAdminGroup/go-admin/adapter/gorilla"
+	gactx "github.com/GoAdminGroup/go-admin/context"
+	"github.com/GoAdminGroup/go-admin/engine"
+	"github.com/GoAdminGroup/go-admin/modules/auth"
+	"github.com/GoAdminGroup/go-admin/modules/config"
+	"github.com/GoAdminGroup/go-admin/modules/db"
+	_ "github.com/GoAdminGroup/go-admin/modules/db/drivers/postgres"
+	"github.com/GoAdminGroup/go-admin/modules/language"
+	"github.com/GoAdminGroup/go-admin/plugins/admin/models"
+	"github.com/GoAdminGroup/go-admin/plugins/admin/modules/table"
+	gatemplate "github.com/GoAdminGroup/go-admin/template"
+	"github.com/GoAdminGroup/go-admin/template/types"
+	_ "github.com/GoAdminGroup/themes/adminlte"
+	"github.com/GoAdminGroup/themes/adminlte/components/infobox"
+	"github.com/gorilla/mux"
+
+	"lingo-sprint/internal/api"
+	"lingo-sprint/internal/audit"
+)
+
+const (
+	prefix        = "/admin"
+	loginPage     = "./web/static/admin-login.html"
+	panelRoleID   = 1   // роль "administrator" из scripts/goadmin.sql
+	noPassword    = "!" // не bcrypt-хеш: войти в панель по паролю нельзя
+	sessionLength = 2 * 60 * 60
+)
+
+type panel struct {
+	h    *api.ApiHandler
+	db   *sql.DB
+	conn db.Connection
+}
+
+// Mount регистрирует панель на роутере. Вызывать до обработчика статики:
+// он перехватывает все пути, не занятые раньше.
+func Mount(r *mux.Router, h *api.ApiHandler, dsn string) error {
+	if dsn == "" {
+		return errors.New("admin: empty database DSN")
+	}
+	cfg := config.Config{
+		Databases: config.DatabaseList{
+			"default": {Dsn: dsn, Driver: config.DriverPostgresql, MaxIdleConns: 2, MaxOpenConns: 5},
+		},
+		UrlPrefix:                     strings.TrimPrefix(prefix, "/"),
+		IndexUrl:                      "/",
+		Language:                      language.EN,
+		Theme:                         "adminlte",
+		Title:                         "Lingo Sprint Admin",
+		Logo:                          "<b>Lingo</b> Sprint",
+		MiniLogo:                      "LS",
+		LoginTitle:                    "Lingo Sprint",
+		Env:                           config.EnvProd,
+		SessionLifeTime:               sessionLength,
+		HideConfigCenterEntrance:      true,
+		HideAppInfoEntrance:           true,
+		HideToolEntrance:              true,
+		HidePluginEntrance:            true,
+		HideVisitorUserCenterEntrance: true,
+	}
+
+	p := &panel{h: h, db: h.DB}
+	eng := engine.Default()
+	router := r.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
+		return req.URL.Path == prefix || strings.HasPrefix(req.URL.Path, prefix+"/")
+	}).Subrouter()
+	router.Use(p.gate)
+
+	err := eng.AddConfig(&cfg).
+		AddGenerators(p.generators()).
+		AddAuthService(p.authenticate).
+		Use(router)
+	if err != nil {
+		return err
+	}
+	p.conn = eng.PostgresqlConnection()
+	eng.HTML("GET", prefix, p.dashboard)
+	return nil
+}
+
+// authenticate - вход в панель (POST /admin/signin) по токену из api.IssuePanelToken.
+// Учетная запись go-admin создается при первом входе: username - users.id, name - email.
+func (p *panel) authenticate(ctx *gactx.Context) (models.UserModel, bool, string) {
+	userID, err := p.h.ParsePanelToken(ctx.FormValue("sso_token"))
+	if err != nil {
+		return models.UserModel{}, false, "Sign in through the app first"
+	}
+	var email, role string
+	err = p.db.QueryRow("SELECT email, role FROM users WHERE id = $1 AND deleted_at IS NULL", userID).Scan(&email, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.UserModel{}, false, "User not found"
+	}
+	if err != nil {
+		log.Printf("admin.authenticate: user %d: %v", userID, err)
+		return models.UserModel{}, false, "Database error"
+	}
+	if role != api.RoleAdmin {
+		return models.UserModel{}, false, "Admin role required"
+	}
+
+	var panelUserID int64
+	err = p.db.QueryRow(`INSERT INTO goadmin_users (username, password, name) VALUES ($1, $2, $3)
+		ON CONFLICT (username) DO UPDATE SET name = EXCLUDED.name, updated_at = NOW() RETURNING id`,
+		strconv.Itoa(userID), noPassword, email).Scan(&panelUserID)
+	if err == nil {
+		_, err = p.db.Exec(`INSERT INTO goadmin_role_users (role_id, user_id) SELECT $1, $2
+			WHERE NOT EXISTS (SELECT 1 FROM goadmin_role_users WHERE role_id = $1 AND user_id = $2)`, panelRoleID, panelUserID)
+	}
+	if err != nil {
+		log.Printf("admin.authenticate: panel account for user %d: %v", userID, err)
+		return models.UserModel{}, false, "Database error"
+	}
+
+	p.h.RecordAudit(ctx.Request, audit.Event{Type: audit.LoginSucceeded, ActorID: userID, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID),
+		Data: map[string]interface{}{"method": "admin_panel"}})
+	return models.User().SetConn(p.conn).Find(panelUserID), true, "ok"
+}
+
+// gate пускает в панель только действующих администраторов приложения: роль проверяется
+// по users на каждом запросе, так что снятие роли или удаление аккаунта закрывает панель сразу.
+// ID администратора кладется в контекст запроса - по нему пишется actor_id в audit_events.
+func (p *panel) gate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		switch {
+		case path == prefix+"/login" && r.Method == http.MethodGet:
+			// Вместо формы логина go-admin - страница входа через сессию приложения
+			http.ServeFile(w, r, loginPage)
+			return
+		case path == prefix+"/install" || strings.HasPrefix(path, prefix+"/install/"):
+			// Мастер установки go-admin не нужен: схему создает scripts/goadmin.sql
+			http.NotFound(w, r)
+			return
+		case path == prefix+"/signin", path == prefix+"/logout", strings.HasPrefix(path, prefix+"/assets/"):
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cookie, err := r.Cookie(auth.DefaultCookieKey)
+		if err != nil || p.conn == nil {
+			// Без сессии go-admin сам отправит на /admin/login
+			next.ServeHTTP(w, r)
+			return
+		}
+		panelUserID := auth.GetUserID(cookie.Value, p.conn)
+		if panelUserID == -1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var userID int
+		var role string
+		err = p.db.QueryRow(`SELECT u.id, u.role FROM goadmin_users g JOIN users u ON u.id::text = g.username
+			WHERE g.id = $1 AND u.deleted_at IS NULL`, panelUserID).Scan(&userID, &role)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("admin.gate: panel user %d: %v", panelUserID, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if err != nil || role != api.RoleAdmin {
+			http.Redirect(w, r, prefix+"/logout", http.StatusSeeOther)
+			return
+		}
+		ctx := context.WithValue(r.Context(), api.ContextUserIDKey, userID)
+		ctx = context.WithValue(ctx, api.ContextRoleKey, role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// dashboard - главная страница панели: сводные цифры.
+func (p *panel) dashboard(ctx *gactx.Context) (types.Panel, error) {
+	stats := []struct {
+		text, icon, color, query string
+	}{
+		{"Users", "ion-ios-people-outline", "aqua", "SELECT COUNT(*) FROM users WHERE role <> 'guest' AND deleted_at IS NULL"},
+		{"Premium", "ion-ios-star-outline", "yellow", "SELECT COUNT(*) FROM users WHERE subscription_status = 'premium' AND deleted_at IS NULL"},
+		{"Sentences", "ion-ios-chatboxes-outline", "green", "SELECT COUNT(*) FROM sentences WHERE archived_at IS NULL"},
+		{"Without audio", "ion-ios-mic-off", "red", "SELECT COUNT(*) FROM sentences WHERE archived_at IS NULL AND (audio_path IS NULL OR audio_path = '')"},
+	}
+	tmpl := gatemplate.Default(ctx)
+	var cols template.HTML
+	for _, s := range stats {
+		var n int
+		if err := p.db.QueryRow(s.query).Scan(&n); err != nil {
+			return types.Panel{}, fmt.Errorf("dashboard %s: %w", s.text, err)
+		}
+		box := infobox.New().
+			SetText(template.HTML(s.text)).
+			SetColor(template.HTML(s.color)).
+			SetNumber(template.HTML(strconv.Itoa(n))).
+			SetIcon(template.HTML(s.icon)).
+			GetContent()
+		cols += tmpl.Col().SetSize(types.SizeMD(3)).SetContent(box).GetContent()
+	}
+	return types.Panel{
+		Title:   "Dashboard",
+		Content: tmpl.Row().SetContent(cols).GetContent(),
+	}, nil
+}
+
+func (p *panel) generators() table.GeneratorList {
+	return table.GeneratorList{
+		"users":     p.usersTable,
+		"courses":   p.coursesTable,
+		"levels":    p.levelsTable,
+		"lessons":   p.lessonsTable,
+		"sentences": p.sentencesTable,
+		"progress":  p.progressTable,
+	}
+}
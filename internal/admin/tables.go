@@ -0,0 +1,368 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	gactx "github.com/GoAdminGroup/go-admin/context"
+	"github.com/GoAdminGroup/go-admin/modules/db"
+	form2 "github.com/GoAdminGroup/go-admin/plugins/admin/modules/form"
+	"github.com/GoAdminGroup/go-admin/plugins/admin/modules/table"
+	"github.com/GoAdminGroup/go-admin/template/types"
+	"github.com/GoAdminGroup/go-admin/template/types/form"
+
+	"lingo-sprint/internal/api"
+)
+
+// Таблицы панели. Чтение go-admin делает сам, а создание, изменение и удаление
+// переопределены (Set*Fn) и идут через методы api.ApiHandler.
+
+func newTable(ctx *gactx.Context, cfg table.Config) table.Table {
+	return table.NewDefaultTable(ctx, cfg)
+}
+
+func baseConfig() table.Config {
+	return table.DefaultConfigWithDriver(db.DriverPostgresql)
+}
+
+// atoi - ID из формы go-admin; пустое или битое значение дает 0, и метод API ответит "not found".
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+var errDatabase = errors.New("Database error")
+
+// formError - ошибка для показа в панели: понятные (ContentError, api.Err*) как есть,
+// ошибки БД - только в лог, как respondWithContentError в API.
+func formError(op string, err error) error {
+	var ce *api.ContentError
+	switch {
+	case err == nil, errors.As(err, &ce),
+		errors.Is(err, api.ErrUserNotFound), errors.Is(err, api.ErrUnknownRole),
+		errors.Is(err, api.ErrLastAdmin), errors.Is(err, api.ErrUnknownSubscription),
+		errors.Is(err, api.ErrEmailNotVerified):
+		return err
+	}
+	log.Printf("admin.%s: %v", op, err)
+	return errDatabase
+}
+
+func deleteEach(op string, ids []string, remove func(id int) error) error {
+	for _, id := range ids {
+		if err := remove(atoi(id)); err != nil {
+			return formError(op, err)
+		}
+	}
+	return nil
+}
+
+func actorID(r *http.Request) int {
+	id, _ := r.Context().Value(api.ContextUserIDKey).(int)
+	return id
+}
+
+func roleOptions() types.FieldOptions {
+	options := types.FieldOptions{}
+	for _, role := range []string{api.RoleUser, api.RoleTeacher, api.RoleContentEditor, api.RoleAdmin} {
+		options = append(options, types.FieldOption{Value: role, Text: role})
+	}
+	return options
+}
+
+func subscriptionOptions() types.FieldOptions {
+	return types.FieldOptions{
+		{Value: api.SubscriptionFree, Text: api.SubscriptionFree},
+		{Value: api.SubscriptionPremium, Text: api.SubscriptionPremium},
+	}
+}
+
+// usersTable - зарегистрированные пользователи; гости не показываются. Меняются только роль и подписка.
+func (p *panel) usersTable(ctx *gactx.Context) table.Table {
+	t := newTable(ctx, baseConfig().SetCanAdd(false).SetDeletable(false))
+
+	info := t.GetInfo().SetTable("users").SetTitle("Users").SetDescription("Registered users").
+		WhereRaw("users.role <> 'guest'")
+	info.AddField("ID", "id", db.Int).FieldSortable()
+	info.AddField("Email", "email", db.Varchar).FieldXssFilter().FieldFilterable(types.FilterType{Operator: types.FilterOperatorLike})
+	info.AddField("Role", "role", db.Varchar).FieldFilterable(types.FilterType{FormType: form.SelectSingle}).FieldFilterOptions(roleOptions())
+	info.AddField("Subscription", "subscription_status", db.Varchar).
+		FieldFilterable(types.FilterType{FormType: form.SelectSingle}).FieldFilterOptions(subscriptionOptions())
+	info.AddField("Registered", "created_at", db.Timestamptz).FieldSortable()
+	info.AddField("Deleted", "deleted_at", db.Timestamptz)
+
+	f := t.GetForm().SetTable("users").SetTitle("User")
+	f.AddField("ID", "id", db.Int, form.Default).FieldDisplayButCanNotEditWhenUpdate()
+	f.AddField("Email", "email", db.Varchar, form.Default).FieldDisplayButCanNotEditWhenUpdate()
+	f.AddField("Role", "role", db.Varchar, form.SelectSingle).FieldOptions(roleOptions()).FieldMust()
+	f.AddField("Subscription", "subscription_status", db.Varchar, form.SelectSingle).FieldOptions(subscriptionOptions()).FieldMust()
+	f.SetUpdateFn(func(values form2.Values) error {
+		targetID := atoi(values.Get("id"))
+		if err := p.h.ChangeUserRole(ctx.Request, actorID(ctx.Request), targetID, values.Get("role")); err != nil {
+			return formError("users", err)
+		}
+		return formError("users", p.h.ChangeSubscription(ctx.Request, actorID(ctx.Request), targetID, values.Get("subscription_status")))
+	})
+	return t
+}
+
+// coursesTable - языковые пары. Код и языки задаются при создании и дальше не меняются.
+func (p *panel) coursesTable(ctx *gactx.Context) table.Table {
+	t := newTable(ctx, baseConfig().SetDeletable(false))
+
+	info := t.GetInfo().SetTable("courses").SetTitle("Courses").SetDescription("Language pairs")
+	info.AddField("ID", "id", db.Int).FieldSortable()
+	info.AddField("Code", "code", db.Varchar).FieldXssFilter().FieldSortable()
+	info.AddField("Title", "title", db.Varchar).FieldXssFilter()
+	info.AddField("From", "source_language", db.Varchar)
+	info.AddField("To", "target_language", db.Varchar)
+	info.AddField("TTS voice", "tts_voice", db.Varchar).FieldXssFilter()
+	info.AddField("Order", "sort_order", db.Int).FieldSortable()
+
+	f := t.GetForm().SetTable("courses").SetTitle("Course")
+	f.AddField("ID", "id", db.Int, form.Default).FieldDisplayButCanNotEditWhenUpdate().FieldHideWhenCreate()
+	f.AddField("Code", "code", db.Varchar, form.Text).FieldMust().FieldDisplayButCanNotEditWhenUpdate().FieldHelpMsg("source-target, e.g. uk-en")
+	f.AddField("Title", "title", db.Varchar, form.Text).FieldMust()
+	f.AddField("From", "source_language", db.Varchar, form.Text).FieldMust().FieldDisplayButCanNotEditWhenUpdate()
+	f.AddField("To", "target_language", db.Varchar, form.Text).FieldMust().FieldDisplayButCanNotEditWhenUpdate()
+	f.AddField("TTS language", "tts_language", db.Varchar, form.Text).FieldMust().FieldHelpMsg("e.g. en-US")
+	f.AddField("TTS voice", "tts_voice", db.Varchar, form.Text).FieldMust().FieldHelpMsg("Changing the voice re-queues the course audio")
+	f.AddField("Order", "sort_order", db.Int, form.Number).FieldDefault("0")
+	courseRequest := func(values form2.Values) api.CourseRequest {
+		sortOrder := atoi(values.Get("sort_order"))
+		return api.CourseRequest{Code: values.Get("code"), Title: values.Get("title"),
+			SourceLanguage: values.Get("source_language"), TargetLanguage: values.Get("target_language"),
+			TTSLanguage: values.Get("tts_language"), TTSVoice: values.Get("tts_voice"), SortOrder: &sortOrder}
+	}
+	f.SetInsertFn(func(values form2.Values) error {
+		_, err := p.h.AddCourse(ctx.Request, courseRequest(values))
+		return formError("courses", err)
+	})
+	f.SetUpdateFn(func(values form2.Values) error {
+		_, err := p.h.EditCourse(ctx.Request, atoi(values.Get("id")), courseRequest(values))
+		return formError("courses", err)
+	})
+	return t
+}
+
+func (p *panel) levelsTable(ctx *gactx.Context) table.Table {
+	t := newTable(ctx, baseConfig())
+
+	info := t.GetInfo().SetTable("levels").SetTitle("Levels").SetDescription("Course levels")
+	info.AddField("ID", "id", db.Int).FieldSortable()
+	info.AddField("Course", "code", db.Varchar).
+		FieldJoin(types.Join{Table: "courses", JoinField: "id", Field: "course_id"}).
+		FieldFilterable(types.FilterType{Operator: types.FilterOperatorLike})
+	info.AddField("Title", "title", db.Varchar).FieldXssFilter().FieldSortable()
+	info.SetDeleteFn(func(ids []string) error {
+		return deleteEach("levels", ids, func(id int) error { return p.h.RemoveLevel(ctx.Request, id) })
+	})
+
+	f := t.GetForm().SetTable("levels").SetTitle("Level")
+	f.AddField("ID", "id", db.Int, form.Default).FieldDisplayButCanNotEditWhenUpdate().FieldHideWhenCreate()
+	f.AddField("Course", "course_id", db.Int, form.SelectSingle).FieldOptionsFromTable("courses", "code", "id").FieldMust().
+		FieldDisplayButCanNotEditWhenUpdate()
+	f.AddField("Title", "title", db.Varchar, form.Text).FieldMust()
+	f.SetInsertFn(func(values form2.Values) error {
+		_, err := p.h.AddLevel(ctx.Request, atoi(values.Get("course_id")), values.Get("title"))
+		return formError("levels", err)
+	})
+	f.SetUpdateFn(func(values form2.Values) error {
+		_, err := p.h.RenameLevel(ctx.Request, atoi(values.Get("id")), values.Get("title"))
+		return formError("levels", err)
+	})
+	return t
+}
+
+func (p *panel) lessonsTable(ctx *gactx.Context) table.Table {
+	t := newTable(ctx, baseConfig())
+
+	info := t.GetInfo().SetTable("lessons").SetTitle("Lessons").SetDescription("Lessons by level")
+	info.AddField("ID", "id", db.Int).FieldSortable()
+	info.AddField("Level", "title", db.Varchar).FieldXssFilter().
+		FieldJoin(types.Join{Table: "levels", JoinField: "id", Field: "level_id"}).
+		FieldFilterable(types.FilterType{Operator: types.FilterOperatorLike})
+	info.AddField("Number", "lesson_number", db.Int).FieldSortable()
+	info.AddField("Title", "title", db.Varchar).FieldXssFilter().FieldFilterable(types.FilterType{Operator: types.FilterOperatorLike})
+	info.AddField("Status", "status", db.Varchar).FieldFilterable(types.FilterType{FormType: form.SelectSingle}).FieldFilterOptions(lessonStatusOptions())
+	info.AddField("Publish at", "publish_at", db.Timestamptz).FieldSortable()
+	info.SetDeleteFn(func(ids []string) error {
+		return deleteEach("lessons", ids, func(id int) error { return p.h.RemoveLesson(ctx.Request, id) })
+	})
+
+	f := t.GetForm().SetTable("lessons").SetTitle("Lesson")
+	f.AddField("ID", "id", db.Int, form.Default).FieldDisplayButCanNotEditWhenUpdate().FieldHideWhenCreate()
+	f.AddField("Level", "level_id", db.Int, form.SelectSingle).FieldOptions(p.levelOptions()).FieldMust()
+	f.AddField("Number", "lesson_number", db.Int, form.Number).FieldMust()
+	f.AddField("Title", "title", db.Varchar, form.Text).FieldMust()
+	f.AddField("Status", "status", db.Varchar, form.SelectSingle).FieldOptions(lessonStatusOptions()).FieldDefault(api.LessonDraft).FieldMust()
+	f.AddField("Publish at", "publish_at", db.Timestamptz, form.Datetime).FieldHelpMsg("Empty - publish immediately")
+	lessonRequest := func(values form2.Values) (api.LessonRequest, error) {
+		publishAt, err := parsePublishAt(values.Get("publish_at"))
+		return api.LessonRequest{LevelID: atoi(values.Get("level_id")), LessonNumber: atoi(values.Get("lesson_number")), Title: values.Get("title"),
+			Status: values.Get("status"), PublishAt: publishAt}, err
+	}
+	f.SetInsertFn(func(values form2.Values) error {
+		req, err := lessonRequest(values)
+		if err != nil {
+			return err
+		}
+		_, err = p.h.AddLesson(ctx.Request, req)
+		return formError("lessons", err)
+	})
+	f.SetUpdateFn(func(values form2.Values) error {
+		req, err := lessonRequest(values)
+		if err != nil {
+			return err
+		}
+		lessonID := atoi(values.Get("id"))
+		if _, err := p.h.EditLesson(ctx.Request, lessonID, req); err != nil {
+			return formError("lessons", err)
+		}
+		return formError("lessons", p.h.SetLessonStatus(ctx.Request, lessonID, api.LessonStatusRequest{Status: req.Status, PublishAt: req.PublishAt}))
+	})
+	return t
+}
+
+func lessonStatusOptions() types.FieldOptions {
+	options := types.FieldOptions{}
+	for _, status := range []string{api.LessonDraft, api.LessonReview, api.LessonPublished, api.LessonArchived} {
+		options = append(options, types.FieldOption{Value: status, Text: status})
+	}
+	return options
+}
+
+// parsePublishAt разбирает дату из поля Datetime go-admin (время сервера); пустое поле - nil.
+func parsePublishAt(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+	if err != nil {
+		return nil, errors.New("publish_at must be a date and time")
+	}
+	return &t, nil
+}
+
+// levelOptions - уровни для выпадающего списка: "ru-en A1" (названия уникальны только в курсе).
+func (p *panel) levelOptions() types.FieldOptions {
+	options := types.FieldOptions{}
+	rows, err := p.db.Query(`SELECT lv.id, c.code, lv.title
+		FROM levels lv JOIN courses c ON c.id = lv.course_id ORDER BY c.sort_order, c.id, lv.sort_order, lv.title`)
+	if err != nil {
+		log.Printf("admin.levelOptions: %v", err)
+		return options
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var course, title string
+		if err := rows.Scan(&id, &course, &title); err != nil {
+			log.Printf("admin.levelOptions: %v", err)
+			return options
+		}
+		options = append(options, types.FieldOption{Value: strconv.Itoa(id), Text: course + " " + title})
+	}
+	return options
+}
+
+// lessonOptions - уроки для выпадающего списка: "ru-en A1 - 3. Название".
+func (p *panel) lessonOptions() types.FieldOptions {
+	options := types.FieldOptions{}
+	rows, err := p.db.Query(`SELECT l.id, c.code, lv.title, l.lesson_number, COALESCE(l.title, '')
+		FROM lessons l JOIN levels lv ON lv.id = l.level_id JOIN courses c ON c.id = lv.course_id
+		ORDER BY c.sort_order, c.id, lv.id, l.lesson_number`)
+	if err != nil {
+		log.Printf("admin.lessonOptions: %v", err)
+		return options
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, number int
+		var course, level, title string
+		if err := rows.Scan(&id, &course, &level, &number, &title); err != nil {
+			log.Printf("admin.lessonOptions: %v", err)
+			return options
+		}
+		options = append(options, types.FieldOption{Value: strconv.Itoa(id), Text: fmt.Sprintf("%s %s - %d. %s", course, level, number, title)})
+	}
+	return options
+}
+
+// sentencesTable - предложения с плеером аудио. Новые добавляются в конец урока;
+// правка текста обнуляет audio_path, и scripts/audio_generator озвучит предложение заново.
+func (p *panel) sentencesTable(ctx *gactx.Context) table.Table {
+	t := newTable(ctx, baseConfig())
+	lessons := p.lessonOptions()
+
+	info := t.GetInfo().SetTable("sentences").SetTitle("Sentences").SetDescription("Sentences by lesson")
+	info.AddField("ID", "id", db.Int).FieldSortable()
+	info.AddField("Lesson", "lesson_id", db.Int).FieldHide().
+		FieldFilterable(types.FilterType{FormType: form.SelectSingle}).FieldFilterOptions(lessons)
+	info.AddField("Lesson", "title", db.Varchar).FieldXssFilter().
+		FieldJoin(types.Join{Table: "lessons", JoinField: "id", Field: "lesson_id"})
+	info.AddField("#", "order_number", db.Int).FieldSortable()
+	info.AddField("Sentence ID", "external_id", db.Varchar).FieldXssFilter().FieldFilterable()
+	info.AddField("Prompt", "prompt", db.Text).FieldXssFilter().FieldFilterable(types.FilterType{Operator: types.FilterOperatorLike})
+	info.AddField("Answer", "answer", db.Text).FieldXssFilter().FieldFilterable(types.FilterType{Operator: types.FilterOperatorLike})
+	info.AddField("Transcription", "transcription", db.Text).FieldXssFilter()
+	info.AddField("Audio", "audio_path", db.Varchar).FieldDisplay(func(value types.FieldModel) interface{} {
+		if value.Value == "" {
+			return "-"
+		}
+		return fmt.Sprintf(`<audio controls preload="none" src="/%s"></audio>`, html.EscapeString(value.Value))
+	})
+	info.AddField("Archived", "archived_at", db.Timestamptz).FieldSortable()
+	info.SetDeleteFn(func(ids []string) error {
+		return deleteEach("sentences", ids, func(id int) error { return p.h.RemoveSentence(ctx.Request, id) })
+	})
+
+	f := t.GetForm().SetTable("sentences").SetTitle("Sentence")
+	f.AddField("ID", "id", db.Int, form.Default).FieldDisplayButCanNotEditWhenUpdate().FieldHideWhenCreate()
+	f.AddField("Lesson", "lesson_id", db.Int, form.SelectSingle).FieldOptions(lessons).FieldMust().FieldDisplayButCanNotEditWhenUpdate()
+	f.AddField("#", "order_number", db.Int, form.Default).FieldDisplayButCanNotEditWhenUpdate().FieldHideWhenCreate()
+	f.AddField("Prompt", "prompt", db.Text, form.TextArea).FieldMust()
+	f.AddField("Answer", "answer", db.Text, form.TextArea).FieldMust()
+	f.AddField("Transcription", "transcription", db.Text, form.Text)
+	sentenceRequest := func(values form2.Values) api.SentenceRequest {
+		return api.SentenceRequest{Prompt: values.Get("prompt"), Answer: values.Get("answer"), Transcription: values.Get("transcription")}
+	}
+	f.SetInsertFn(func(values form2.Values) error {
+		_, err := p.h.AddSentence(ctx.Request, atoi(values.Get("lesson_id")), sentenceRequest(values))
+		return formError("sentences", err)
+	})
+	f.SetUpdateFn(func(values form2.Values) error {
+		_, err := p.h.EditSentence(ctx.Request, atoi(values.Get("id")), sentenceRequest(values))
+		return formError("sentences", err)
+	})
+	return t
+}
+
+// progressTable - прогресс учеников, только просмотр.
+func (p *panel) progressTable(ctx *gactx.Context) table.Table {
+	t := newTable(ctx, baseConfig().SetCanAdd(false).SetEditable(false).SetDeletable(false))
+
+	info := t.GetInfo().SetTable("user_progress").SetTitle("Progress").SetDescription("Learner progress by sentence").SetSortDesc()
+	info.AddField("ID", "id", db.Int).FieldSortable()
+	info.AddField("User", "email", db.Varchar).FieldXssFilter().
+		FieldJoin(types.Join{Table: "users", JoinField: "id", Field: "user_id"}).
+		FieldFilterable(types.FilterType{Operator: types.FilterOperatorLike})
+	info.AddField("Sentence", "prompt", db.Text).FieldXssFilter().
+		FieldJoin(types.Join{Table: "sentences", JoinField: "id", Field: "sentence_id"})
+	info.AddField("Status", "status", db.Varchar).FieldFilterable(types.FilterType{FormType: form.SelectSingle}).
+		FieldFilterOptions(types.FieldOptions{
+			{Value: "new", Text: "new"},
+			{Value: "learning", Text: "learning"},
+			{Value: "mastered", Text: "mastered"},
+		})
+	info.AddField("Streak", "correct_streak", db.Int).FieldSortable()
+	info.AddField("Mistakes", "mistake_count", db.Int).FieldSortable()
+	info.AddField("Next review", "next_review_date", db.Timestamptz).FieldSortable()
+	info.AddField("Updated", "updated_at", db.Timestamptz).FieldSortable()
+	return t
+}
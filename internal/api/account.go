@@ -0,0 +1,310 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"lingo-sprint/internal/audit"
+)
+
+const (
+	minPasswordLen = 6  // как на лендинге
+	maxPasswordLen = 72 // bcrypt учитывает только первые 72 байта
+
+	emailChangeTokenTTL = 24 * time.Hour
+
+	// У аккаунта без пароля (вход через OIDC) вместо пароля подходит вход не старше этого
+	recentLoginWindow = 15 * time.Minute
+
+	ActionChangePassword = "change_password"
+)
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLen {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLen)
+	}
+	if len(password) > maxPasswordLen {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordLen)
+	}
+	return nil
+}
+
+// checkCurrentPassword сверяет пароль пользователя с учетом лимита попыток.
+// Пишет ответ сам и возвращает false, если продолжать нельзя.
+func (h *ApiHandler) checkCurrentPassword(w http.ResponseWriter, r *http.Request, userID int, password string) bool {
+	return h.checkLimited(w, r, userID, "Current password is incorrect", func() (bool, error) {
+		var hash string
+		if err := h.DB.QueryRow("SELECT password_hash FROM users WHERE id = $1", userID).Scan(&hash); err != nil {
+			return false, err
+		}
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, nil
+	})
+}
+
+// checkLimited выполняет проверку личности check под общим лимитом попыток ActionChangePassword:
+// пароль и код 2FA подбираются через украденную сессию одинаково.
+func (h *ApiHandler) checkLimited(w http.ResponseWriter, r *http.Request, userID int, failMessage string, check func() (bool, error)) bool {
+	userKey := "user:" + strconv.Itoa(userID)
+	wait, err := h.Attempts.Check(ActionChangePassword, userKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return false
+	}
+
+	ok, err := check()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if !ok {
+		if _, err := h.Attempts.Fail(ActionChangePassword, userKey, ThrottlePolicies[ActionChangePassword].MaxFailures, clientIP(r)); err != nil {
+			log.Printf("checkLimited: failed to record attempt: %v", err)
+		}
+		respondWithError(w, http.StatusUnauthorized, failMessage)
+		return false
+	}
+	if err := h.Attempts.Reset(ActionChangePassword, userKey); err != nil {
+		log.Printf("checkLimited: failed to reset attempts: %v", err)
+	}
+	return true
+}
+
+// reauthenticate подтверждает личность перед чувствительным действием. Аккаунту с паролем
+// нужен текущий пароль. У аккаунта без пароля (создан через OIDC) его нет, поэтому подходит
+// код 2FA (или код восстановления), а без кода - сессия, открытая не раньше recentLoginWindow.
+// Пишет ответ сам и возвращает false, если продолжать нельзя.
+func (h *ApiHandler) reauthenticate(w http.ResponseWriter, r *http.Request, userID int, password, code string) bool {
+	var hasPassword bool
+	if err := h.DB.QueryRow("SELECT has_password FROM users WHERE id = $1", userID).Scan(&hasPassword); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if hasPassword {
+		return h.checkCurrentPassword(w, r, userID, password)
+	}
+	if code != "" {
+		return h.checkLimited(w, r, userID, "Invalid code", func() (bool, error) {
+			return h.verifySecondFactor(userID, code)
+		})
+	}
+	recent, err := h.isRecentLogin(r)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if !recent {
+		respondWithError(w, http.StatusForbidden, "A 2FA code or a recent login is required")
+		return false
+	}
+	return true
+}
+
+// isRecentLogin - текущая сессия открыта не раньше recentLoginWindow (только что вошли заново).
+func (h *ApiHandler) isRecentLogin(r *http.Request) (bool, error) {
+	sessionID, _ := r.Context().Value(ContextSessionIDKey).(string)
+	sid, err := strconv.Atoi(sessionID)
+	if err != nil {
+		return false, nil
+	}
+	var recent bool
+	err = h.DB.QueryRow("SELECT created_at > $2 FROM user_sessions WHERE id = $1", sid, time.Now().Add(-recentLoginWindow)).Scan(&recent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return recent, err
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Code            string `json:"code,omitempty"` // аккаунт без пароля: код 2FA вместо current_password
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangePassword (POST /api/me/password) меняет пароль и отзывает все остальные сессии.
+// Аккаунт, созданный через OIDC, так задает себе первый пароль (см. reauthenticate).
+func (h *ApiHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	sessionID, _ := r.Context().Value(ContextSessionIDKey).(string)
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if allowed, err := h.isActionAllowed(userID, ActionPasswordReset); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	} else if !allowed {
+		respondWithError(w, http.StatusForbidden, "Email verification required")
+		return
+	}
+	if !h.reauthenticate(w, r, userID, req.CurrentPassword, req.Code) {
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+	tx, err := h.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE users SET password_hash = $1, has_password = TRUE WHERE id = $2", string(hash), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if err := revokeOtherSessions(tx, userID, sessionID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	h.recordAudit(r, audit.Event{Type: audit.PasswordChanged, TargetType: audit.TargetUser, TargetID: userTarget(userID)})
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Password changed"})
+}
+
+type ChangeEmailRequest struct {
+	NewEmail        string `json:"new_email"`
+	CurrentPassword string `json:"current_password"`
+	Code            string `json:"code,omitempty"` // аккаунт без пароля: код 2FA вместо current_password
+}
+
+// RequestEmailChange (POST /api/me/email) отправляет ссылку подтверждения на новый адрес.
+// Сам email меняется только после перехода по ссылке (ConfirmEmailChange).
+func (h *ApiHandler) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	var req ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	newEmail := normalizeEmail(req.NewEmail)
+	if err := validateEmail(newEmail); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid email address")
+		return
+	}
+	if !h.reauthenticate(w, r, userID, req.CurrentPassword, req.Code) {
+		return
+	}
+
+	var taken bool
+	if err := h.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(email) = $1)", newEmail).Scan(&taken); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if taken {
+		respondWithError(w, http.StatusConflict, "Email already exists")
+		return
+	}
+
+	token, tokenHash, err := randomToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	// Действует только последний запрос на смену
+	if _, err := h.DB.Exec("UPDATE email_change_requests SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL", userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	_, err = h.DB.Exec("INSERT INTO email_change_requests (user_id, new_email, token_hash, expires_at) VALUES ($1, $2, $3, $4)",
+		userID, newEmail, tokenHash, time.Now().Add(emailChangeTokenTTL))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	link := fmt.Sprintf("%s/api/me/email/confirm?token=%s", AppBaseURL, token)
+	body := fmt.Sprintf("Здравствуйте!\n\nВы запросили смену email в Lingo Sprint на этот адрес. Чтобы подтвердить, перейдите по ссылке:\n%s\n\nЕсли это были не вы, просто проигнорируйте письмо.", link)
+	if err := h.Mailer.Send(newEmail, "Lingo Sprint: подтвердите новый email", body); err != nil {
+		log.Printf("RequestEmailChange: failed to send confirmation for user %d: %v", userID, err)
+		respondWithError(w, http.StatusBadGateway, "Failed to send confirmation email")
+		return
+	}
+	h.recordAudit(r, audit.Event{Type: audit.EmailChangeRequested, TargetType: audit.TargetUser, TargetID: userTarget(userID),
+		Data: map[string]interface{}{"new_email": newEmail}})
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"message": "Confirmation link sent to the new address"})
+}
+
+// ConfirmEmailChange обрабатывает ссылку из письма: меняет email и уведомляет старый адрес.
+func (h *ApiHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Redirect(w, r, "/?email_changed=0", http.StatusSeeOther)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var newEmail string
+	err = tx.QueryRow(`UPDATE email_change_requests SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id, new_email`, hashToken(token)).Scan(&userID, &newEmail)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("ConfirmEmailChange: token lookup failed: %v", err)
+		}
+		http.Redirect(w, r, "/?email_changed=0", http.StatusSeeOther)
+		return
+	}
+
+	var oldEmail string
+	if err := tx.QueryRow("SELECT email FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&oldEmail); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	// Адрес мог занять кто-то другой, пока письмо шло; уникальный индекс это тоже поймает
+	if _, err := tx.Exec("UPDATE users SET email = $1, email_verified_at = NOW() WHERE id = $2", newEmail, userID); err != nil {
+		log.Printf("ConfirmEmailChange: failed to update email for user %d: %v", userID, err)
+		http.Redirect(w, r, "/?email_changed=0", http.StatusSeeOther)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	h.recordAudit(r, audit.Event{Type: audit.EmailChanged, ActorID: userID, TargetType: audit.TargetUser, TargetID: userTarget(userID),
+		Data: map[string]interface{}{"old_email": oldEmail, "new_email": newEmail}})
+
+	body := fmt.Sprintf("Здравствуйте!\n\nEmail вашего аккаунта Lingo Sprint был изменен на %s.\n\nЕсли это были не вы, срочно свяжитесь с поддержкой.", newEmail)
+	if err := h.Mailer.Send(oldEmail, "Lingo Sprint: email аккаунта изменен", body); err != nil {
+		log.Printf("ConfirmEmailChange: failed to notify old address of user %d: %v", userID, err)
+	}
+	http.Redirect(w, r, "/?email_changed=1", http.StatusSeeOther)
+}
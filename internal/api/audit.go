@@ -0,0 +1,89 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lingo-sprint/internal/audit"
+)
+
+// recordAudit пишет событие в audit_events, дополняя его данными запроса: IP, User-Agent,
+// текущим пользователем (если ActorID не задан) и администратором при имперсонации.
+// Ошибка записи не прерывает запрос, но попадает в лог.
+func (h *ApiHandler) recordAudit(r *http.Request, e audit.Event) {
+	if r != nil {
+		if e.ActorID == 0 {
+			e.ActorID, _ = r.Context().Value(ContextUserIDKey).(int)
+		}
+		if e.ImpersonatorID == 0 {
+			e.ImpersonatorID, _ = r.Context().Value(ContextImpersonatorKey).(int)
+		}
+		e.IP = clientIP(r)
+		e.UserAgent = truncate(r.UserAgent(), 255)
+	}
+	if err := h.Audit.Record(e); err != nil {
+		log.Printf("Audit: failed to record %s (actor %d, target %s/%s): %v", e.Type, e.ActorID, e.TargetType, e.TargetID, err)
+	}
+}
+
+// userTarget - ID пользователя в виде, в котором он хранится в audit_events.target_id.
+func userTarget(userID int) string {
+	return strconv.Itoa(userID)
+}
+
+// QueryAuditEvents (GET /api/admin/audit-events) - журнал с фильтрами:
+// actor_id, target_type, target_id, event_type (можно "auth.*"), from/to (RFC 3339), before_id, limit.
+func (h *ApiHandler) QueryAuditEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := audit.Filter{
+		TargetType: q.Get("target_type"),
+		TargetID:   q.Get("target_id"),
+		Type:       q.Get("event_type"),
+	}
+	var err error
+	if v := q.Get("actor_id"); v != "" {
+		if f.ActorID, err = strconv.Atoi(v); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid actor_id")
+			return
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			respondWithError(w, http.StatusBadRequest, "from must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			respondWithError(w, http.StatusBadRequest, "to must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	if v := q.Get("before_id"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid before_id")
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+	}
+
+	events, err := h.Audit.Query(f)
+	if err != nil {
+		log.Printf("QueryAuditEvents: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	response := map[string]interface{}{"events": events}
+	if len(events) > 0 {
+		// Следующая страница: ?before_id=<next_before_id> с теми же фильтрами
+		response["next_before_id"] = events[len(events)-1].ID
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
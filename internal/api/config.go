@@ -1,4 +1,66 @@
 package api
 
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // JwtKey - это секретный ключ для подписи и проверки JWT-токенов.
-var JwtKey = []byte("my_very_secret_and_long_key_32_bytes")
\ No newline at end of file
+var JwtKey = []byte("my_very_secret_and_long_key_32_bytes")
+
+// AppBaseURL - публичный адрес приложения, из него строятся ссылки в письмах.
+var AppBaseURL = strings.TrimRight(envOrDefault("APP_BASE_URL", "http://localhost:8080"), "/")
+
+// Действия, которые можно запретить аккаунтам с неподтвержденным email.
+const (
+	ActionLogin           = "login"
+	ActionPremiumPurchase = "premium_purchase" // переход на premium (ChangeSubscription)
+	ActionPasswordReset   = "password_reset"   // смена пароля (POST /api/me/password)
+)
+
+// UnverifiedRestrictions - что НЕ разрешено делать до подтверждения email.
+// Задается через UNVERIFIED_RESTRICTIONS списком через запятую, например "login,premium_purchase".
+var UnverifiedRestrictions = parseSet(envOrDefault("UNVERIFIED_RESTRICTIONS", ActionPremiumPurchase+","+ActionPasswordReset))
+
+// MFARequiredRoles - роли, чьи права (RequirePermission, RequireRole) действуют только в сессии,
+// подтвержденной 2FA; после входа без нее клиенту предлагается ее настроить.
+var MFARequiredRoles = parseSet(envOrDefault("MFA_REQUIRED_ROLES", "teacher,admin"))
+
+// AccountDeletionGrace - сколько удаленный аккаунт ждет окончательной очистки (ACCOUNT_DELETION_GRACE_DAYS).
+var AccountDeletionGrace = time.Duration(envInt("ACCOUNT_DELETION_GRACE_DAYS", 30)) * 24 * time.Hour
+
+// Бесплатная часть курса: первые FREE_LESSONS_PER_LEVEL уроков каждого уровня и уровни FREE_LEVELS целиком.
+// Гости видят только ее.
+var (
+	FreeLessonsPerLevel = envInt("FREE_LESSONS_PER_LEVEL", 5)
+	FreeLevels          = parseSet(envOrDefault("FREE_LEVELS", "A0"))
+)
+
+func envOrDefault(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// parseSet превращает "a, b,c" в множество {a, b, c}.
+func parseSet(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			set[item] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,872 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+
+	"lingo-sprint/internal/audit"
+	"lingo-sprint/internal/content"
+	"lingo-sprint/internal/models"
+)
+
+// Редактирование контента (уровни, уроки, предложения) - то же, что делает scripts/data_loader,
+// но без правки CSV. Методы Add*/Edit*/Remove* используют и REST-эндпоинты (право PermContentEdit),
+// и админ-панель internal/admin, поэтому проверки и журнал у них общие.
+// Любая правка текста предложения обнуляет audio_path: scripts/audio_generator озвучит его заново.
+const (
+	maxLevelTitleLen  = 50
+	maxLessonTitleLen = 255
+)
+
+// ContentError - ошибка, которую можно показать редактору как есть; API отдает ее с кодом Status.
+type ContentError struct {
+	Status  int
+	Message string
+}
+
+func (e *ContentError) Error() string {
+	return e.Message
+}
+
+func contentError(status int, message string) error {
+	return &ContentError{Status: status, Message: message}
+}
+
+// respondWithContentError отвечает текстом ContentError, остальные ошибки считает ошибками БД.
+func respondWithContentError(w http.ResponseWriter, op string, err error) {
+	var ce *ContentError
+	if errors.As(err, &ce) {
+		respondWithError(w, ce.Status, ce.Message)
+		return
+	}
+	log.Printf("%s: %v", op, err)
+	respondWithError(w, http.StatusInternalServerError, "Database error")
+}
+
+// AdminSentence - предложение в том виде, в котором его видит редактор (без прогресса).
+type AdminSentence struct {
+	ID            int     `json:"id"`
+	LessonID      int     `json:"lesson_id"`
+	OrderNumber   int     `json:"order_number"`
+	Prompt        string  `json:"prompt"`
+	Answer        string  `json:"answer"`
+	Transcription string  `json:"transcription"`
+	AudioPath     *string `json:"audio_path"`
+}
+
+type LevelRequest struct {
+	CourseID int    `json:"course_id"` // только при создании; 0 - курс по умолчанию
+	Title    string `json:"title"`
+}
+
+type LessonRequest struct {
+	LevelID      int    `json:"level_id"` // при создании берется из URL; при изменении 0 - оставить прежний
+	LessonNumber int    `json:"lesson_number"`
+	Title        string `json:"title"`
+
+	// Только при создании (дальше - PUT .../status): по умолчанию черновик, ученикам не виден
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+type SentenceRequest struct {
+	Prompt        string `json:"prompt"`
+	Answer        string `json:"answer"`
+	Transcription string `json:"transcription"`
+	Position      int    `json:"position"` // только при создании: место в уроке (1 - первым), 0 - в конец
+
+	// Только при изменении: что сделать с прогрессом учеников, если ответ изменился по существу
+	// (keep, downgrade, reset; по умолчанию downgrade - mastered вернется в повторение)
+	ProgressPolicy string `json:"progress_policy"`
+}
+
+type ReorderRequest struct {
+	SentenceIDs []int `json:"sentence_ids"` // все предложения урока в новом порядке
+}
+
+func pathID(r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	return id, err == nil
+}
+
+func contentTarget(id int) string {
+	return strconv.Itoa(id)
+}
+
+// --- Уровни ---
+
+func validateLevelTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" || utf8.RuneCountInString(title) > maxLevelTitleLen {
+		return "", contentError(http.StatusBadRequest, "title must be 1-50 characters")
+	}
+	return title, nil
+}
+
+// AddLevel создает уровень в курсе courseID (0 - курс по умолчанию). Название уникально в пределах курса.
+func (h *ApiHandler) AddLevel(r *http.Request, courseID int, title string) (models.Level, error) {
+	title, err := validateLevelTitle(title)
+	if err != nil {
+		return models.Level{}, err
+	}
+	if courseID == 0 {
+		if err := h.DB.QueryRow("SELECT " + defaultCourseSQL).Scan(&courseID); err != nil {
+			return models.Level{}, err
+		}
+	} else if _, err := h.loadCourse(courseID); err != nil {
+		return models.Level{}, err
+	}
+	level := models.Level{CourseID: courseID, Title: title}
+	err = h.DB.QueryRow("INSERT INTO levels (course_id, title) VALUES ($1, $2) ON CONFLICT (course_id, title) DO NOTHING RETURNING id",
+		courseID, title).Scan(&level.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return level, contentError(http.StatusConflict, "Level with this title already exists")
+	}
+	if err != nil {
+		return level, err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentCreated, TargetType: audit.TargetLevel, TargetID: contentTarget(level.ID),
+		Data: map[string]interface{}{"course_id": level.CourseID, "title": level.Title}})
+	return level, nil
+}
+
+// RenameLevel меняет название уровня.
+func (h *ApiHandler) RenameLevel(r *http.Request, levelID int, title string) (models.Level, error) {
+	title, err := validateLevelTitle(title)
+	if err != nil {
+		return models.Level{}, err
+	}
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return models.Level{}, err
+	}
+	defer tx.Rollback()
+
+	var oldTitle string
+	var courseID int
+	err = tx.QueryRow("SELECT title, course_id FROM levels WHERE id = $1 FOR UPDATE", levelID).Scan(&oldTitle, &courseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Level{}, contentError(http.StatusNotFound, "Level not found")
+	}
+	if err != nil {
+		return models.Level{}, err
+	}
+	var taken bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM levels WHERE course_id = $1 AND title = $2 AND id <> $3)",
+		courseID, title, levelID).Scan(&taken); err != nil {
+		return models.Level{}, err
+	}
+	if taken {
+		return models.Level{}, contentError(http.StatusConflict, "Level with this title already exists")
+	}
+	if _, err := tx.Exec("UPDATE levels SET title = $1 WHERE id = $2", title, levelID); err != nil {
+		return models.Level{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Level{}, err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentUpdated, TargetType: audit.TargetLevel, TargetID: contentTarget(levelID),
+		Data: map[string]interface{}{"title": map[string]string{"old": oldTitle, "new": title}}})
+	return models.Level{ID: levelID, CourseID: courseID, Title: title}, nil
+}
+
+// RemoveLevel удаляет пустой уровень.
+func (h *ApiHandler) RemoveLevel(r *http.Request, levelID int) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var title string
+	err = tx.QueryRow("SELECT title FROM levels WHERE id = $1 FOR UPDATE", levelID).Scan(&title)
+	if errors.Is(err, sql.ErrNoRows) {
+		return contentError(http.StatusNotFound, "Level not found")
+	}
+	if err != nil {
+		return err
+	}
+	var hasLessons bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM lessons WHERE level_id = $1)", levelID).Scan(&hasLessons); err != nil {
+		return err
+	}
+	if hasLessons {
+		return contentError(http.StatusConflict, "Level has lessons, delete or move them first")
+	}
+	if _, err := tx.Exec("DELETE FROM levels WHERE id = $1", levelID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentDeleted, TargetType: audit.TargetLevel, TargetID: contentTarget(levelID),
+		Data: map[string]interface{}{"title": title}})
+	return nil
+}
+
+// CreateLevel (POST /api/admin/levels).
+func (h *ApiHandler) CreateLevel(w http.ResponseWriter, r *http.Request) {
+	var req LevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	level, err := h.AddLevel(r, req.CourseID, req.Title)
+	if err != nil {
+		respondWithContentError(w, "CreateLevel", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, level)
+}
+
+// UpdateLevel (PUT /api/admin/levels/{level_id}) переименовывает уровень.
+func (h *ApiHandler) UpdateLevel(w http.ResponseWriter, r *http.Request) {
+	levelID, ok := pathID(r, "level_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid level ID")
+		return
+	}
+	var req LevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	level, err := h.RenameLevel(r, levelID, req.Title)
+	if err != nil {
+		respondWithContentError(w, "UpdateLevel", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, level)
+}
+
+// DeleteLevel (DELETE /api/admin/levels/{level_id}) удаляет пустой уровень.
+func (h *ApiHandler) DeleteLevel(w http.ResponseWriter, r *http.Request) {
+	levelID, ok := pathID(r, "level_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid level ID")
+		return
+	}
+	if err := h.RemoveLevel(r, levelID); err != nil {
+		respondWithContentError(w, "DeleteLevel", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- Уроки ---
+
+func validateLesson(req *LessonRequest) error {
+	req.Title = strings.TrimSpace(req.Title)
+	if req.LessonNumber < 1 {
+		return contentError(http.StatusBadRequest, "lesson_number must be positive")
+	}
+	if req.Title == "" || utf8.RuneCountInString(req.Title) > maxLessonTitleLen {
+		return contentError(http.StatusBadRequest, "title must be 1-255 characters")
+	}
+	return nil
+}
+
+// lockLevel блокирует уровень: создание и перенос уроков в нем идут по очереди.
+func lockLevel(tx *sql.Tx, levelID int) error {
+	err := tx.QueryRow("SELECT id FROM levels WHERE id = $1 FOR UPDATE", levelID).Scan(&levelID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return contentError(http.StatusNotFound, "Level not found")
+	}
+	return err
+}
+
+// checkLessonNumber - номер урока не занят в уровне (exceptID - сам редактируемый урок).
+func checkLessonNumber(tx *sql.Tx, levelID, lessonNumber, exceptID int) error {
+	var taken bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM lessons WHERE level_id = $1 AND lesson_number = $2 AND id <> $3)",
+		levelID, lessonNumber, exceptID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return contentError(http.StatusConflict, "Lesson with this number already exists in the level")
+	}
+	return nil
+}
+
+// AddLesson создает урок в уровне req.LevelID.
+func (h *ApiHandler) AddLesson(r *http.Request, req LessonRequest) (models.Lesson, error) {
+	if err := validateLesson(&req); err != nil {
+		return models.Lesson{}, err
+	}
+	if req.Status == "" {
+		req.Status = LessonDraft
+	}
+	if !IsValidLessonStatus(req.Status) {
+		return models.Lesson{}, contentError(http.StatusBadRequest, "status must be one of draft, review, published, archived")
+	}
+	// Черновик или урок на проверке создает любой редактор, опубликовать (или сразу убрать в архив) - только с правом публикации
+	if role, _ := r.Context().Value(ContextRoleKey).(string); req.Status != LessonDraft && req.Status != LessonReview &&
+		!HasPermission(role, PermContentPublish) {
+		return models.Lesson{}, contentError(http.StatusForbidden, "Publishing lessons requires the content.publish permission")
+	}
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return models.Lesson{}, err
+	}
+	defer tx.Rollback()
+
+	if err := lockLevel(tx, req.LevelID); err != nil {
+		return models.Lesson{}, err
+	}
+	if err := checkLessonNumber(tx, req.LevelID, req.LessonNumber, 0); err != nil {
+		return models.Lesson{}, err
+	}
+	lesson := models.Lesson{LevelID: req.LevelID, LessonNumber: req.LessonNumber, Title: req.Title, Status: req.Status, PublishAt: req.PublishAt}
+	if err := tx.QueryRow("INSERT INTO lessons (level_id, lesson_number, title, status, publish_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		req.LevelID, req.LessonNumber, req.Title, req.Status, req.PublishAt).Scan(&lesson.ID); err != nil {
+		return models.Lesson{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Lesson{}, err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentCreated, TargetType: audit.TargetLesson, TargetID: contentTarget(lesson.ID),
+		Data: map[string]interface{}{"level_id": lesson.LevelID, "lesson_number": lesson.LessonNumber, "title": lesson.Title,
+			"status": lesson.Status, "publish_at": lesson.PublishAt}})
+	return lesson, nil
+}
+
+// EditLesson меняет номер, название или уровень урока.
+func (h *ApiHandler) EditLesson(r *http.Request, lessonID int, req LessonRequest) (models.Lesson, error) {
+	if err := validateLesson(&req); err != nil {
+		return models.Lesson{}, err
+	}
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return models.Lesson{}, err
+	}
+	defer tx.Rollback()
+
+	var old models.Lesson
+	var oldTitle sql.NullString
+	err = tx.QueryRow("SELECT level_id, lesson_number, title FROM lessons WHERE id = $1 FOR UPDATE", lessonID).
+		Scan(&old.LevelID, &old.LessonNumber, &oldTitle)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Lesson{}, contentError(http.StatusNotFound, "Lesson not found")
+	}
+	if err != nil {
+		return models.Lesson{}, err
+	}
+	if req.LevelID == 0 {
+		req.LevelID = old.LevelID
+	}
+	if req.LevelID != old.LevelID {
+		if err := lockLevel(tx, req.LevelID); err != nil {
+			return models.Lesson{}, err
+		}
+	}
+	if err := checkLessonNumber(tx, req.LevelID, req.LessonNumber, lessonID); err != nil {
+		return models.Lesson{}, err
+	}
+	if _, err := tx.Exec("UPDATE lessons SET level_id = $1, lesson_number = $2, title = $3 WHERE id = $4",
+		req.LevelID, req.LessonNumber, req.Title, lessonID); err != nil {
+		return models.Lesson{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Lesson{}, err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentUpdated, TargetType: audit.TargetLesson, TargetID: contentTarget(lessonID),
+		Data: map[string]interface{}{
+			"old": map[string]interface{}{"level_id": old.LevelID, "lesson_number": old.LessonNumber, "title": oldTitle.String},
+			"new": map[string]interface{}{"level_id": req.LevelID, "lesson_number": req.LessonNumber, "title": req.Title},
+		}})
+	return models.Lesson{ID: lessonID, LevelID: req.LevelID, LessonNumber: req.LessonNumber, Title: req.Title}, nil
+}
+
+// RemoveLesson удаляет урок без предложений.
+func (h *ApiHandler) RemoveLesson(r *http.Request, lessonID int) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var levelID, number int
+	err = tx.QueryRow("SELECT level_id, lesson_number FROM lessons WHERE id = $1 FOR UPDATE", lessonID).Scan(&levelID, &number)
+	if errors.Is(err, sql.ErrNoRows) {
+		return contentError(http.StatusNotFound, "Lesson not found")
+	}
+	if err != nil {
+		return err
+	}
+	var hasSentences bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM sentences WHERE lesson_id = $1)", lessonID).Scan(&hasSentences); err != nil {
+		return err
+	}
+	if hasSentences {
+		return contentError(http.StatusConflict, "Lesson has sentences, delete them first")
+	}
+	if _, err := tx.Exec("DELETE FROM lessons WHERE id = $1", lessonID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentDeleted, TargetType: audit.TargetLesson, TargetID: contentTarget(lessonID),
+		Data: map[string]interface{}{"level_id": levelID, "lesson_number": number}})
+	return nil
+}
+
+// CreateLesson (POST /api/admin/levels/{level_id}/lessons).
+func (h *ApiHandler) CreateLesson(w http.ResponseWriter, r *http.Request) {
+	levelID, ok := pathID(r, "level_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid level ID")
+		return
+	}
+	var req LessonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.LevelID = levelID
+	lesson, err := h.AddLesson(r, req)
+	if err != nil {
+		respondWithContentError(w, "CreateLesson", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, lesson)
+}
+
+// UpdateLesson (PUT /api/admin/lessons/{lesson_id}) меняет номер, название или уровень урока.
+func (h *ApiHandler) UpdateLesson(w http.ResponseWriter, r *http.Request) {
+	lessonID, ok := pathID(r, "lesson_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid lesson ID")
+		return
+	}
+	var req LessonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	lesson, err := h.EditLesson(r, lessonID, req)
+	if err != nil {
+		respondWithContentError(w, "UpdateLesson", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, lesson)
+}
+
+// DeleteLesson (DELETE /api/admin/lessons/{lesson_id}) удаляет урок без предложений.
+func (h *ApiHandler) DeleteLesson(w http.ResponseWriter, r *http.Request) {
+	lessonID, ok := pathID(r, "lesson_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid lesson ID")
+		return
+	}
+	if err := h.RemoveLesson(r, lessonID); err != nil {
+		respondWithContentError(w, "DeleteLesson", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- Предложения ---
+
+func validateSentence(req *SentenceRequest) error {
+	req.Prompt = strings.TrimSpace(req.Prompt)
+	req.Answer = strings.TrimSpace(req.Answer)
+	req.Transcription = strings.TrimSpace(req.Transcription)
+	if req.Prompt == "" || req.Answer == "" {
+		return contentError(http.StatusBadRequest, "prompt and answer are required")
+	}
+	return nil
+}
+
+// lockLessonSentences блокирует урок (все изменения порядка в нем идут по очереди)
+// и возвращает ID его предложений по порядку. Архивные (убранные загрузчиком) не участвуют.
+func lockLessonSentences(tx *sql.Tx, lessonID int) ([]int, error) {
+	var id int
+	err := tx.QueryRow("SELECT id FROM lessons WHERE id = $1 FOR UPDATE", lessonID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, contentError(http.StatusNotFound, "Lesson not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query("SELECT id FROM sentences WHERE lesson_id = $1 AND archived_at IS NULL ORDER BY order_number FOR UPDATE", lessonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int{}
+	for rows.Next() {
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// renumberSentences проставляет order_number = 1..N в порядке ids. Ограничение
+// UNIQUE (lesson_id, order_number) проверяется на каждой строке, поэтому сначала все
+// номера урока уводятся в отрицательные, и промежуточные состояния не пересекаются.
+// Архивные предложения лежат ниже -1000000000 (scripts/init.sql) и с этим диапазоном не сталкиваются.
+func renumberSentences(tx *sql.Tx, lessonID int, ids []int) error {
+	if _, err := tx.Exec("UPDATE sentences SET order_number = -order_number WHERE lesson_id = $1 AND order_number > 0", lessonID); err != nil {
+		return err
+	}
+	for i, id := range ids {
+		if _, err := tx.Exec("UPDATE sentences SET order_number = $1 WHERE id = $2 AND lesson_id = $3", i+1, id, lessonID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanAdminSentence(row interface{ Scan(...interface{}) error }) (AdminSentence, error) {
+	var s AdminSentence
+	var transcription, audioPath sql.NullString
+	err := row.Scan(&s.ID, &s.LessonID, &s.OrderNumber, &s.Prompt, &s.Answer, &transcription, &audioPath)
+	s.Transcription = transcription.String
+	if audioPath.Valid && audioPath.String != "" {
+		s.AudioPath = &audioPath.String
+	}
+	return s, err
+}
+
+const adminSentenceColumns = "id, lesson_id, order_number, prompt, answer, transcription, audio_path"
+
+// AddSentence добавляет предложение в конец урока или на место req.Position, сдвигая следующие.
+func (h *ApiHandler) AddSentence(r *http.Request, lessonID int, req SentenceRequest) (AdminSentence, error) {
+	if err := validateSentence(&req); err != nil {
+		return AdminSentence{}, err
+	}
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return AdminSentence{}, err
+	}
+	defer tx.Rollback()
+
+	ids, err := lockLessonSentences(tx, lessonID)
+	if err != nil {
+		return AdminSentence{}, err
+	}
+	if req.Position < 0 || req.Position > len(ids)+1 {
+		return AdminSentence{}, contentError(http.StatusBadRequest, "position is out of range")
+	}
+
+	// Сначала в конец (номер заведомо свободен), потом при необходимости - на нужное место
+	var maxOrder int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(order_number), 0) FROM sentences WHERE lesson_id = $1", lessonID).Scan(&maxOrder); err != nil {
+		return AdminSentence{}, err
+	}
+	var sentenceID int
+	// sentence_id для CSV (scripts/data_loader) - следующий свободный числовой ключ урока
+	err = tx.QueryRow(`INSERT INTO sentences (lesson_id, order_number, external_id, prompt, answer, transcription, audio_path)
+		VALUES ($1, $2, (SELECT (COALESCE(MAX(external_id::int), 0) + 1)::text FROM sentences
+			WHERE lesson_id = $1 AND external_id ~ '^[0-9]{1,9}$'), $3, $4, NULLIF($5, ''), NULL) RETURNING id`,
+		lessonID, maxOrder+1, req.Prompt, req.Answer, req.Transcription).Scan(&sentenceID)
+	if err != nil {
+		return AdminSentence{}, err
+	}
+	if _, err := content.RecordRevision(tx, h.editorRevision(r, sentenceID, req)); err != nil {
+		return AdminSentence{}, err
+	}
+	order := append(ids, sentenceID)
+	if req.Position > 0 && req.Position <= len(ids) {
+		order = append(append(append([]int{}, ids[:req.Position-1]...), sentenceID), ids[req.Position-1:]...)
+	}
+	if err := renumberSentences(tx, lessonID, order); err != nil {
+		return AdminSentence{}, err
+	}
+	s, err := scanAdminSentence(tx.QueryRow("SELECT "+adminSentenceColumns+" FROM sentences WHERE id = $1", sentenceID))
+	if err != nil {
+		return AdminSentence{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return AdminSentence{}, err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentCreated, TargetType: audit.TargetSentence, TargetID: contentTarget(sentenceID),
+		Data: map[string]interface{}{"lesson_id": lessonID, "order_number": s.OrderNumber, "prompt": s.Prompt, "answer": s.Answer}})
+	return s, nil
+}
+
+// editorRevision - версия предложения от текущего редактора.
+func (h *ApiHandler) editorRevision(r *http.Request, sentenceID int, req SentenceRequest) content.Revision {
+	userID, _ := r.Context().Value(ContextUserIDKey).(int)
+	return content.Revision{SentenceID: sentenceID, Prompt: req.Prompt, Answer: req.Answer, Transcription: req.Transcription,
+		ChangedBy: userID, Source: content.SourceEditor}
+}
+
+// EditSentence меняет текст предложения и сохраняет новую версию в sentence_revisions.
+// Как и scripts/data_loader, обнуляет audio_path, чтобы аудио сгенерировалось заново.
+// Если ответ изменился по существу, к прогрессу учеников применяется req.ProgressPolicy.
+func (h *ApiHandler) EditSentence(r *http.Request, sentenceID int, req SentenceRequest) (AdminSentence, error) {
+	if err := validateSentence(&req); err != nil {
+		return AdminSentence{}, err
+	}
+	if req.ProgressPolicy == "" {
+		req.ProgressPolicy = content.DefaultPolicy
+	}
+	if !content.IsValidPolicy(req.ProgressPolicy) {
+		return AdminSentence{}, contentError(http.StatusBadRequest, "progress_policy must be one of keep, downgrade, reset")
+	}
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return AdminSentence{}, err
+	}
+	defer tx.Rollback()
+
+	old, err := scanAdminSentence(tx.QueryRow("SELECT "+adminSentenceColumns+" FROM sentences WHERE id = $1 FOR UPDATE", sentenceID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return AdminSentence{}, contentError(http.StatusNotFound, "Sentence not found")
+	}
+	if err != nil {
+		return AdminSentence{}, err
+	}
+	if content.Hash(old.Prompt, old.Answer, old.Transcription) == content.Hash(req.Prompt, req.Answer, req.Transcription) {
+		// Ничего не изменилось: ни новой версии, ни повторной озвучки
+		return old, nil
+	}
+	// Озвучивается только answer: при правке подсказки или транскрипции аудио остается
+	if _, err := tx.Exec(`UPDATE sentences SET prompt = $1, answer = $2, transcription = NULLIF($3, ''),
+		audio_path = CASE WHEN answer = $2 THEN audio_path END WHERE id = $4`,
+		req.Prompt, req.Answer, req.Transcription, sentenceID); err != nil {
+		return AdminSentence{}, err
+	}
+	rev := h.editorRevision(r, sentenceID, req)
+	if content.MaterialChange(old.Answer, req.Answer) {
+		rev.Policy = req.ProgressPolicy
+		if rev.Affected, err = content.ApplyProgressPolicy(tx, sentenceID, rev.Policy); err != nil {
+			return AdminSentence{}, err
+		}
+	}
+	revision, err := content.RecordRevision(tx, rev)
+	if err != nil {
+		return AdminSentence{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return AdminSentence{}, err
+	}
+	data := map[string]interface{}{
+		"old":      map[string]string{"prompt": old.Prompt, "answer": old.Answer, "transcription": old.Transcription},
+		"new":      map[string]string{"prompt": req.Prompt, "answer": req.Answer, "transcription": req.Transcription},
+		"revision": revision,
+	}
+	if rev.Policy != "" {
+		data["progress_policy"] = rev.Policy
+		data["affected_progress"] = rev.Affected
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentUpdated, TargetType: audit.TargetSentence, TargetID: contentTarget(sentenceID), Data: data})
+	updated := AdminSentence{ID: sentenceID, LessonID: old.LessonID, OrderNumber: old.OrderNumber,
+		Prompt: req.Prompt, Answer: req.Answer, Transcription: req.Transcription}
+	if old.Answer == req.Answer {
+		updated.AudioPath = old.AudioPath
+	}
+	return updated, nil
+}
+
+// RemoveSentence удаляет предложение и сдвигает следующие, чтобы номера в уроке
+// остались 1..N. Предложение с прогрессом учеников не удаляется.
+func (h *ApiHandler) RemoveSentence(r *http.Request, sentenceID int) error {
+	var lessonID int
+	err := h.DB.QueryRow("SELECT lesson_id FROM sentences WHERE id = $1", sentenceID).Scan(&lessonID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return contentError(http.StatusNotFound, "Sentence not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ids, err := lockLessonSentences(tx, lessonID)
+	if err != nil {
+		return err
+	}
+	remaining := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if id != sentenceID {
+			remaining = append(remaining, id)
+		}
+	}
+	if len(remaining) == len(ids) {
+		// Предложение успели удалить или перенести, пока мы ждали блокировку
+		return contentError(http.StatusNotFound, "Sentence not found")
+	}
+	var hasProgress bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM user_progress WHERE sentence_id = $1)
+		OR EXISTS (SELECT 1 FROM user_attempts WHERE sentence_id = $1)`, sentenceID).Scan(&hasProgress); err != nil {
+		return err
+	}
+	if hasProgress {
+		return contentError(http.StatusConflict, "Sentence has learner progress and cannot be deleted")
+	}
+	old, err := scanAdminSentence(tx.QueryRow("DELETE FROM sentences WHERE id = $1 RETURNING "+adminSentenceColumns, sentenceID))
+	if err != nil {
+		return err
+	}
+	if err := renumberSentences(tx, lessonID, remaining); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentDeleted, TargetType: audit.TargetSentence, TargetID: contentTarget(sentenceID),
+		Data: map[string]interface{}{"lesson_id": lessonID, "prompt": old.Prompt, "answer": old.Answer}})
+	return nil
+}
+
+// ReorderLesson задает новый порядок предложений урока. ids - все предложения урока,
+// каждое по одному разу. ID предложений (и прогресс по ним) не меняются, меняется только order_number.
+func (h *ApiHandler) ReorderLesson(r *http.Request, lessonID int, ids []int) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := lockLessonSentences(tx, lessonID)
+	if err != nil {
+		return err
+	}
+	if !samePermutation(current, ids) {
+		return contentError(http.StatusBadRequest, "sentence_ids must list every sentence of the lesson exactly once")
+	}
+	if err := renumberSentences(tx, lessonID, ids); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentUpdated, TargetType: audit.TargetLesson, TargetID: contentTarget(lessonID),
+		Data: map[string]interface{}{"sentence_order": map[string][]int{"old": current, "new": ids}}})
+	return nil
+}
+
+// samePermutation проверяет, что got - перестановка want.
+func samePermutation(want, got []int) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	pending := make(map[int]bool, len(want))
+	for _, id := range want {
+		pending[id] = true
+	}
+	for _, id := range got {
+		if !pending[id] {
+			return false
+		}
+		delete(pending, id)
+	}
+	return true
+}
+
+// ListLessonSentences (GET /api/admin/lessons/{lesson_id}/sentences) - предложения урока для редактора.
+func (h *ApiHandler) ListLessonSentences(w http.ResponseWriter, r *http.Request) {
+	lessonID, ok := pathID(r, "lesson_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid lesson ID")
+		return
+	}
+	rows, err := h.DB.Query("SELECT "+adminSentenceColumns+" FROM sentences WHERE lesson_id = $1 AND archived_at IS NULL ORDER BY order_number", lessonID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+	sentences := []AdminSentence{}
+	for rows.Next() {
+		s, err := scanAdminSentence(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		sentences = append(sentences, s)
+	}
+	respondWithJSON(w, http.StatusOK, sentences)
+}
+
+// CreateSentence (POST /api/admin/lessons/{lesson_id}/sentences).
+func (h *ApiHandler) CreateSentence(w http.ResponseWriter, r *http.Request) {
+	lessonID, ok := pathID(r, "lesson_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid lesson ID")
+		return
+	}
+	var req SentenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	s, err := h.AddSentence(r, lessonID, req)
+	if err != nil {
+		respondWithContentError(w, "CreateSentence", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, s)
+}
+
+// UpdateSentence (PUT /api/admin/sentences/{sentence_id}) меняет текст предложения.
+func (h *ApiHandler) UpdateSentence(w http.ResponseWriter, r *http.Request) {
+	sentenceID, ok := pathID(r, "sentence_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid sentence ID")
+		return
+	}
+	var req SentenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	s, err := h.EditSentence(r, sentenceID, req)
+	if err != nil {
+		respondWithContentError(w, "UpdateSentence", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, s)
+}
+
+// DeleteSentence (DELETE /api/admin/sentences/{sentence_id}).
+func (h *ApiHandler) DeleteSentence(w http.ResponseWriter, r *http.Request) {
+	sentenceID, ok := pathID(r, "sentence_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid sentence ID")
+		return
+	}
+	if err := h.RemoveSentence(r, sentenceID); err != nil {
+		respondWithContentError(w, "DeleteSentence", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderSentences (PUT /api/admin/lessons/{lesson_id}/sentences/order).
+func (h *ApiHandler) ReorderSentences(w http.ResponseWriter, r *http.Request) {
+	lessonID, ok := pathID(r, "lesson_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid lesson ID")
+		return
+	}
+	var req ReorderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.ReorderLesson(r, lessonID, req.SentenceIDs); err != nil {
+		respondWithContentError(w, "ReorderSentences", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"lesson_id": lessonID, "sentence_ids": req.SentenceIDs})
+}
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"lingo-sprint/internal/audit"
+	"lingo-sprint/internal/models"
+)
+
+// Курс - языковая пара (ru-en, uk-en, ru-de): уровни принадлежат курсу, у курса свой голос озвучки.
+// Ученик видит уровни активного курса (user_profiles.active_course_id); NULL - курс по умолчанию,
+// первый по sort_order. Контент, загруженный до появления курсов, относится к ru-en.
+const maxCourseTitleLen = 100
+
+const courseColumns = "id, code, title, source_language, target_language, tts_language, tts_voice, sort_order"
+
+// defaultCourseSQL - ID курса по умолчанию.
+const defaultCourseSQL = "(SELECT id FROM courses ORDER BY sort_order, id LIMIT 1)"
+
+// activeCourseSQL - ID активного курса пользователя $1.
+const activeCourseSQL = "COALESCE((SELECT active_course_id FROM user_profiles WHERE user_id = $1), " + defaultCourseSQL + ")"
+
+// languageNames - названия языков для подсказок ИИ (по-английски: инструкции модели тоже на английском).
+var languageNames = map[string]string{
+	"ru": "Russian",
+	"uk": "Ukrainian",
+	"be": "Belarusian",
+	"kk": "Kazakh",
+	"en": "English",
+	"de": "German",
+	"fr": "French",
+	"es": "Spanish",
+	"it": "Italian",
+	"pl": "Polish",
+}
+
+func languageName(code string) string {
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	return code
+}
+
+type CourseRequest struct {
+	// Код и языки задаются только при создании; при изменении пустое значение - оставить прежнее
+	Code           string `json:"code"`
+	Title          string `json:"title"`
+	SourceLanguage string `json:"source_language"`
+	TargetLanguage string `json:"target_language"`
+	TTSLanguage    string `json:"tts_language"`
+	TTSVoice       string `json:"tts_voice"`
+	SortOrder      *int   `json:"sort_order"`
+}
+
+func scanCourse(row interface{ Scan(...interface{}) error }) (models.Course, error) {
+	var c models.Course
+	err := row.Scan(&c.ID, &c.Code, &c.Title, &c.SourceLanguage, &c.TargetLanguage, &c.TTSLanguage, &c.TTSVoice, &c.SortOrder)
+	return c, err
+}
+
+func validateCourse(c *models.Course) error {
+	c.Title = strings.TrimSpace(c.Title)
+	if c.Title == "" || utf8.RuneCountInString(c.Title) > maxCourseTitleLen {
+		return contentError(http.StatusBadRequest, "title must be 1-100 characters")
+	}
+	if !languageCodeRe.MatchString(c.SourceLanguage) || !languageCodeRe.MatchString(c.TargetLanguage) {
+		return contentError(http.StatusBadRequest, "source_language and target_language must be ISO 639 codes")
+	}
+	if c.SourceLanguage == c.TargetLanguage {
+		return contentError(http.StatusBadRequest, "source_language and target_language must differ")
+	}
+	if c.Code != c.SourceLanguage+"-"+c.TargetLanguage {
+		return contentError(http.StatusBadRequest, "code must be source_language-target_language, e.g. ru-en")
+	}
+	if !localeRe.MatchString(c.TTSLanguage) || !strings.HasPrefix(c.TTSLanguage, c.TargetLanguage) {
+		return contentError(http.StatusBadRequest, "tts_language must look like en-US and match target_language")
+	}
+	if !ttsVoiceRe.MatchString(c.TTSVoice) || !strings.HasPrefix(c.TTSVoice, c.TTSLanguage+"-") {
+		return contentError(http.StatusBadRequest, "tts_voice must look like en-US-Standard-F and match tts_language")
+	}
+	return nil
+}
+
+// loadCourse читает курс по ID.
+func (h *ApiHandler) loadCourse(courseID int) (models.Course, error) {
+	c, err := scanCourse(h.DB.QueryRow("SELECT "+courseColumns+" FROM courses WHERE id = $1", courseID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, contentError(http.StatusNotFound, "Course not found")
+	}
+	return c, err
+}
+
+// loadActiveCourse - курс, уровни которого видит пользователь.
+func (h *ApiHandler) loadActiveCourse(userID int) (models.Course, error) {
+	return scanCourse(h.DB.QueryRow("SELECT "+courseColumns+" FROM courses WHERE id = "+activeCourseSQL, userID))
+}
+
+// AddCourse создает курс.
+func (h *ApiHandler) AddCourse(r *http.Request, req CourseRequest) (models.Course, error) {
+	c := models.Course{
+		Code:           strings.ToLower(strings.TrimSpace(req.Code)),
+		Title:          req.Title,
+		SourceLanguage: strings.ToLower(strings.TrimSpace(req.SourceLanguage)),
+		TargetLanguage: strings.ToLower(strings.TrimSpace(req.TargetLanguage)),
+		TTSLanguage:    strings.TrimSpace(req.TTSLanguage),
+		TTSVoice:       strings.TrimSpace(req.TTSVoice),
+	}
+	if req.SortOrder != nil {
+		c.SortOrder = *req.SortOrder
+	}
+	if err := validateCourse(&c); err != nil {
+		return c, err
+	}
+	err := h.DB.QueryRow(`INSERT INTO courses (code, title, source_language, target_language, tts_language, tts_voice, sort_order)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (code) DO NOTHING RETURNING id`,
+		c.Code, c.Title, c.SourceLanguage, c.TargetLanguage, c.TTSLanguage, c.TTSVoice, c.SortOrder).Scan(&c.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, contentError(http.StatusConflict, "Course with this code already exists")
+	}
+	if err != nil {
+		return c, err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentCreated, TargetType: audit.TargetCourse, TargetID: contentTarget(c.ID),
+		Data: map[string]interface{}{"code": c.Code, "title": c.Title, "tts_voice": c.TTSVoice}})
+	return c, nil
+}
+
+// EditCourse меняет название, порядок и озвучку курса. Код и языки не меняются: под них
+// уже написаны предложения. Новый голос обнуляет audio_path предложений курса -
+// scripts/audio_generator озвучит их заново.
+func (h *ApiHandler) EditCourse(r *http.Request, courseID int, req CourseRequest) (models.Course, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return models.Course{}, err
+	}
+	defer tx.Rollback()
+
+	old, err := scanCourse(tx.QueryRow("SELECT "+courseColumns+" FROM courses WHERE id = $1 FOR UPDATE", courseID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return old, contentError(http.StatusNotFound, "Course not found")
+	}
+	if err != nil {
+		return old, err
+	}
+	if (req.Code != "" && req.Code != old.Code) ||
+		(req.SourceLanguage != "" && req.SourceLanguage != old.SourceLanguage) ||
+		(req.TargetLanguage != "" && req.TargetLanguage != old.TargetLanguage) {
+		return old, contentError(http.StatusConflict, "Course code and languages cannot be changed")
+	}
+	c := old
+	if req.Title != "" {
+		c.Title = req.Title
+	}
+	if req.TTSLanguage != "" {
+		c.TTSLanguage = strings.TrimSpace(req.TTSLanguage)
+	}
+	if req.TTSVoice != "" {
+		c.TTSVoice = strings.TrimSpace(req.TTSVoice)
+	}
+	if req.SortOrder != nil {
+		c.SortOrder = *req.SortOrder
+	}
+	if err := validateCourse(&c); err != nil {
+		return c, err
+	}
+	if _, err := tx.Exec("UPDATE courses SET title = $1, tts_language = $2, tts_voice = $3, sort_order = $4 WHERE id = $5",
+		c.Title, c.TTSLanguage, c.TTSVoice, c.SortOrder, courseID); err != nil {
+		return c, err
+	}
+	if c.TTSVoice != old.TTSVoice || c.TTSLanguage != old.TTSLanguage {
+		if _, err := tx.Exec(`UPDATE sentences s SET audio_path = NULL FROM lessons l, levels lv
+			WHERE l.id = s.lesson_id AND lv.id = l.level_id AND lv.course_id = $1 AND s.audio_path IS NOT NULL`, courseID); err != nil {
+			return c, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return c, err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentUpdated, TargetType: audit.TargetCourse, TargetID: contentTarget(courseID),
+		Data: map[string]interface{}{
+			"old": map[string]interface{}{"title": old.Title, "tts_voice": old.TTSVoice, "sort_order": old.SortOrder},
+			"new": map[string]interface{}{"title": c.Title, "tts_voice": c.TTSVoice, "sort_order": c.SortOrder},
+		}})
+	return c, nil
+}
+
+// ListCourses (GET /api/courses) - курсы, в которых есть хотя бы один опубликованный урок,
+// и какой из них активен у пользователя.
+func (h *ApiHandler) ListCourses(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	rows, err := h.DB.Query(`SELECT ` + courseColumns + ` FROM courses c
+		WHERE EXISTS (SELECT 1 FROM levels lv JOIN lessons l ON l.level_id = lv.id
+			WHERE lv.course_id = c.id AND ` + publishedLessonSQL + `)
+		ORDER BY sort_order, id`)
+	if err != nil {
+		log.Printf("ListCourses: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+	courses := []models.Course{}
+	for rows.Next() {
+		c, err := scanCourse(rows)
+		if err != nil {
+			log.Printf("ListCourses: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		courses = append(courses, c)
+	}
+	active, err := h.loadActiveCourse(userID)
+	if err != nil {
+		log.Printf("ListCourses: active course of user %d: %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"courses": courses, "active_course_id": active.ID})
+}
+
+// CreateCourse (POST /api/admin/courses).
+func (h *ApiHandler) CreateCourse(w http.ResponseWriter, r *http.Request) {
+	var req CourseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	course, err := h.AddCourse(r, req)
+	if err != nil {
+		respondWithContentError(w, "CreateCourse", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, course)
+}
+
+// UpdateCourse (PUT /api/admin/courses/{course_id}).
+func (h *ApiHandler) UpdateCourse(w http.ResponseWriter, r *http.Request) {
+	courseID, ok := pathID(r, "course_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid course ID")
+		return
+	}
+	var req CourseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	course, err := h.EditCourse(r, courseID, req)
+	if err != nil {
+		respondWithContentError(w, "UpdateCourse", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, course)
+}
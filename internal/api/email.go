@@ -0,0 +1,169 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"lingo-sprint/internal/audit"
+)
+
+const (
+	verificationTokenTTL   = 24 * time.Hour
+	verificationResendWait = time.Minute
+)
+
+// normalizeEmail приводит email к единому виду: без пробелов по краям и в нижнем регистре.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// validateEmail проверяет синтаксис адреса. Имена вида "Ivan <ivan@x.ru>" не принимаем.
+func validateEmail(email string) error {
+	if len(email) > 255 {
+		return errors.New("email is too long")
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return errors.New("invalid email address")
+	}
+	at := strings.LastIndex(email, "@")
+	domain := email[at+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return errors.New("invalid email domain")
+	}
+	return nil
+}
+
+// randomToken возвращает случайный токен для ссылки и его SHA-256 хеш для хранения в БД.
+func randomToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sendVerificationEmail выпускает новый токен подтверждения (старые гасит) и отправляет письмо.
+func (h *ApiHandler) sendVerificationEmail(userID int, email string) error {
+	token, tokenHash, err := randomToken()
+	if err != nil {
+		return err
+	}
+	_, err = h.DB.Exec("UPDATE email_verification_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL", userID)
+	if err != nil {
+		return err
+	}
+	_, err = h.DB.Exec("INSERT INTO email_verification_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)",
+		userID, tokenHash, time.Now().Add(verificationTokenTTL))
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/verify-email?token=%s", AppBaseURL, token)
+	body := fmt.Sprintf("Здравствуйте!\n\nПодтвердите адрес электронной почты для Lingo Sprint, перейдя по ссылке:\n%s\n\nСсылка действует %d часа.", link, int(verificationTokenTTL.Hours()))
+	return h.Mailer.Send(email, "Lingo Sprint: подтвердите email", body)
+}
+
+// VerifyEmail обрабатывает переход по ссылке из письма и перенаправляет на лендинг.
+func (h *ApiHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Redirect(w, r, "/?email_verified=0", http.StatusSeeOther)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(`UPDATE email_verification_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`, hashToken(token)).Scan(&userID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("VerifyEmail: token lookup failed: %v", err)
+		}
+		http.Redirect(w, r, "/?email_verified=0", http.StatusSeeOther)
+		return
+	}
+	if _, err := tx.Exec("UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1", userID); err != nil {
+		log.Printf("VerifyEmail: failed to mark user %d verified: %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	h.recordAudit(r, audit.Event{Type: audit.EmailVerified, ActorID: userID, TargetType: audit.TargetUser, TargetID: userTarget(userID)})
+	http.Redirect(w, r, "/?email_verified=1", http.StatusSeeOther)
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// ResendVerification отправляет письмо повторно. Ответ всегда одинаковый,
+// чтобы по нему нельзя было узнать, зарегистрирован ли адрес.
+func (h *ApiHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	email := normalizeEmail(req.Email)
+	response := map[string]string{"message": "If the account exists and is not verified, a new link has been sent"}
+
+	var userID int
+	var lastSent sql.NullTime
+	err := h.DB.QueryRow(`SELECT u.id, (SELECT MAX(created_at) FROM email_verification_tokens WHERE user_id = u.id)
+		FROM users u WHERE LOWER(u.email) = $1 AND u.email_verified_at IS NULL`, email).Scan(&userID, &lastSent)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("ResendVerification: lookup failed: %v", err)
+		}
+		respondWithJSON(w, http.StatusOK, response)
+		return
+	}
+	if lastSent.Valid && time.Since(lastSent.Time) < verificationResendWait {
+		respondWithError(w, http.StatusTooManyRequests, "Please wait before requesting another email")
+		return
+	}
+	if err := h.sendVerificationEmail(userID, email); err != nil {
+		log.Printf("ResendVerification: failed to send to user %d: %v", userID, err)
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// isActionAllowed проверяет, может ли пользователь выполнить действие с учетом UnverifiedRestrictions.
+func (h *ApiHandler) isActionAllowed(userID int, action string) (bool, error) {
+	if !UnverifiedRestrictions[action] {
+		return true, nil
+	}
+	var verified bool
+	err := h.DB.QueryRow("SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1", userID).Scan(&verified)
+	if err != nil {
+		return false, err
+	}
+	return verified, nil
+}
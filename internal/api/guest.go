@@ -0,0 +1,207 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Гость - строка в users с ролью "guest" и без email. Так весь код прогресса
+// (user_progress, user_attempts, статистика) работает для гостей без изменений.
+const (
+	guestTTL    = 30 * 24 * time.Hour
+	ActionGuest = "guest"
+)
+
+var errInvalidGuestToken = errors.New("invalid guest token")
+
+// isFreeLesson - урок из бесплатной части курса (ее же показывает app.js без замка).
+func isFreeLesson(lessonNumber int, levelTitle string) bool {
+	return lessonNumber <= FreeLessonsPerLevel || FreeLevels[levelTitle]
+}
+
+// lessonAvailableToGuest проверяет урок по ID; sentenceID используется, если lessonID == 0.
+func (h *ApiHandler) lessonAvailableToGuest(lessonID, sentenceID int) (bool, error) {
+	var number int
+	var levelTitle string
+	var err error
+	if lessonID != 0 {
+		err = h.DB.QueryRow(`SELECT l.lesson_number, lv.title FROM lessons l JOIN levels lv ON lv.id = l.level_id
+			WHERE l.id = $1`, lessonID).Scan(&number, &levelTitle)
+	} else {
+		err = h.DB.QueryRow(`SELECT l.lesson_number, lv.title FROM sentences s
+			JOIN lessons l ON l.id = s.lesson_id JOIN levels lv ON lv.id = l.level_id
+			WHERE s.id = $1`, sentenceID).Scan(&number, &levelTitle)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return isFreeLesson(number, levelTitle), nil
+}
+
+// DenyGuests закрывает эндпоинты, которые имеют смысл только для зарегистрированных (аккаунт, ИИ и т.п.).
+func DenyGuests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if role, _ := r.Context().Value(ContextRoleKey).(string); role == RoleGuest {
+			respondWithError(w, http.StatusForbidden, "Sign up to use this feature")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StartGuestSession (POST /api/guest) заводит гостя и выдает ему токен.
+// Токен такой же, как у обычной сессии, но с ролью "guest".
+func (h *ApiHandler) StartGuestSession(w http.ResponseWriter, r *http.Request) {
+	passwordHash, err := unusablePasswordHash()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+	var guestID int
+	if err := h.DB.QueryRow("INSERT INTO users (password_hash, role) VALUES ($1, $2) RETURNING id", passwordHash, RoleGuest).Scan(&guestID); err != nil {
+		log.Printf("StartGuestSession: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	token, err := h.startSession(r, &Claims{UserID: guestID, Role: RoleGuest}, guestTTL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{"token": token, "role": RoleGuest})
+}
+
+// guestFromToken проверяет токен гостя и возвращает его ID.
+func (h *ApiHandler) guestFromToken(tokenString string) (int, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return JwtKey, nil
+	})
+	if err != nil || !token.Valid || claims.Purpose != "" || claims.Role != RoleGuest {
+		return 0, errInvalidGuestToken
+	}
+	active, err := h.isSessionActive(claims.ID, claims.UserID)
+	if err != nil {
+		return 0, err
+	}
+	if !active {
+		return 0, errInvalidGuestToken
+	}
+	return claims.UserID, nil
+}
+
+// progressRank - порядок статусов: при конфликте побеждает более "продвинутая" запись.
+const progressRank = `CASE %s WHEN 'mastered' THEN 2 WHEN 'learning' THEN 1 ELSE 0 END`
+
+// guestWins - запись гостя лучше записи аккаунта: выше статус, при равном статусе - длиннее серия.
+var guestWins = fmt.Sprintf("(%s, EXCLUDED.correct_streak) > (%s, user_progress.correct_streak)",
+	fmt.Sprintf(progressRank, "EXCLUDED.status"), fmt.Sprintf(progressRank, "user_progress.status"))
+
+// mergeGuest переносит прогресс гостя в аккаунт userID и удаляет гостя. Конфликты по предложению:
+// статус, серия и дата повторения берутся из лучшей записи, ошибки суммируются
+// (от них зависят звезды), updated_at - самый поздний. Возвращает число перенесенных записей.
+func (h *ApiHandler) mergeGuest(guestID, userID int) (int, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Блокировка строки гостя не дает слить одного гостя в два аккаунта одновременно
+	var attempts, correct int
+	err = tx.QueryRow("SELECT COALESCE(total_attempts, 0), COALESCE(total_correct, 0) FROM users WHERE id = $1 AND role = $2 FOR UPDATE",
+		guestID, RoleGuest).Scan(&attempts, &correct)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errInvalidGuestToken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO user_progress (user_id, sentence_id, status, correct_streak, next_review_date, updated_at, mistake_count)
+		SELECT $1, sentence_id, status, correct_streak, next_review_date, updated_at, mistake_count
+		FROM user_progress WHERE user_id = $2
+		ON CONFLICT (user_id, sentence_id) DO UPDATE SET
+			status = CASE WHEN `+guestWins+` THEN EXCLUDED.status ELSE user_progress.status END,
+			correct_streak = CASE WHEN `+guestWins+` THEN EXCLUDED.correct_streak ELSE user_progress.correct_streak END,
+			next_review_date = CASE WHEN `+guestWins+` THEN EXCLUDED.next_review_date ELSE user_progress.next_review_date END,
+			mistake_count = user_progress.mistake_count + EXCLUDED.mistake_count,
+			updated_at = GREATEST(user_progress.updated_at, EXCLUDED.updated_at)`, userID, guestID)
+	if err != nil {
+		return 0, err
+	}
+	merged, _ := res.RowsAffected()
+
+	if _, err := tx.Exec("UPDATE user_attempts SET user_id = $1 WHERE user_id = $2", userID, guestID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("UPDATE users SET total_attempts = COALESCE(total_attempts, 0) + $1, total_correct = COALESCE(total_correct, 0) + $2 WHERE id = $3",
+		attempts, correct, userID); err != nil {
+		return 0, err
+	}
+	// Прогресс и сессии гостя удаляются каскадом
+	if _, err := tx.Exec("DELETE FROM users WHERE id = $1", guestID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	log.Printf("Guest %d merged into user %d (%d progress rows)", guestID, userID, merged)
+	return int(merged), nil
+}
+
+type MergeGuestRequest struct {
+	GuestToken string `json:"guest_token"`
+}
+
+// MergeGuest (POST /api/me/merge-guest) переносит прогресс гостя в аккаунт после входа.
+func (h *ApiHandler) MergeGuest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	var req MergeGuestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	guestID, err := h.guestFromToken(req.GuestToken)
+	var merged int
+	if err == nil {
+		merged, err = h.mergeGuest(guestID, userID)
+	}
+	if errors.Is(err, errInvalidGuestToken) {
+		respondWithError(w, http.StatusBadRequest, "Invalid guest token")
+		return
+	}
+	if err != nil {
+		log.Printf("MergeGuest: user %d: %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to merge guest progress")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]int{"merged": merged})
+}
+
+// PurgeExpiredGuests удаляет гостей, у которых не осталось действующих сессий
+// (только что созданного гостя без сессии не трогаем).
+func (h *ApiHandler) PurgeExpiredGuests() (int, error) {
+	res, err := h.DB.Exec(`DELETE FROM users u WHERE u.role = $1 AND u.created_at < NOW() - INTERVAL '1 hour' AND NOT EXISTS (
+		SELECT 1 FROM user_sessions s WHERE s.user_id = u.id AND s.revoked_at IS NULL AND s.expires_at > NOW())`, RoleGuest)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
@@ -10,50 +10,124 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 
+	"lingo-sprint/internal/audit"
+	"lingo-sprint/internal/mail"
 	"lingo-sprint/internal/models"
+	"lingo-sprint/internal/oidc"
 )
 
 type ApiHandler struct {
-	DB *sql.DB
+	DB            *sql.DB
+	Mailer        mail.Sender
+	Attempts      *AttemptStore
+	OIDCProviders map[string]*oidc.Provider
+	Audit         *audit.Log
 }
 
 func NewApiHandler(db *sql.DB) *ApiHandler {
-	return &ApiHandler{DB: db}
+	providers, err := oidc.LoadProviders(os.Getenv("OIDC_PROVIDERS"))
+	if err != nil {
+		log.Printf("OIDC login disabled: %v", err)
+		providers = map[string]*oidc.Provider{}
+	}
+	return &ApiHandler{DB: db, Mailer: mail.NewFromEnv(), Attempts: &AttemptStore{DB: db}, OIDCProviders: providers, Audit: audit.New(db)}
 }
 
 type Credentials struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	GuestToken string `json:"guest_token,omitempty"` // при регистрации: перенести прогресс гостя
 }
 
 type Claims struct {
-	UserID int `json:"user_id"`
+	UserID  int    `json:"user_id"`
+	Role    string `json:"role,omitempty"`    // users.role на момент входа
+	Purpose string `json:"purpose,omitempty"` // непустой только у служебных токенов (например, "mfa"), для API они не годятся
+	MFA     bool   `json:"mfa,omitempty"`     // вход подтвержден вторым фактором
+
+	// Имперсонация (impersonation.go): кто из администраторов действует от имени UserID
+	ImpersonatorID int  `json:"impersonator_id,omitempty"`
+	AllowWrite     bool `json:"allow_write,omitempty"`
 	jwt.RegisteredClaims
 }
 
+const sessionTTL = 72 * time.Hour
+
+// issueToken подписывает JWT с заданным сроком жизни.
+func issueToken(claims *Claims, ttl time.Duration) (string, error) {
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(ttl))
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(JwtKey)
+}
+
+// respondWithSession открывает сессию и выдает ее токен после успешного входа.
+func (h *ApiHandler) respondWithSession(w http.ResponseWriter, r *http.Request, userID int, role string, mfa bool) {
+	if err := h.restoreIfDeleted(r, userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	tokenString, err := h.startSession(r, &Claims{UserID: userID, Role: role, MFA: mfa}, sessionTTL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	h.recordAudit(r, audit.Event{Type: audit.LoginSucceeded, ActorID: userID, TargetType: audit.TargetUser, TargetID: userTarget(userID),
+		Data: map[string]interface{}{"method": "password", "mfa": mfa}})
+	response := map[string]interface{}{"token": tokenString, "role": role}
+	if !mfa && MFARequiredRoles[role] {
+		// Для ролей с повышенными правами клиент должен предложить настроить 2FA
+		response["mfa_enrollment_required"] = true
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
 func (h *ApiHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	var creds Credentials
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
+	creds.Email = normalizeEmail(creds.Email)
+	if err := validateEmail(creds.Email); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid email address")
+		return
+	}
+	if err := validatePassword(creds.Password); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
 		return
 	}
-	_, err = h.DB.Exec("INSERT INTO users (email, password_hash) VALUES ($1, $2)", creds.Email, string(hashedPassword))
+	// Уникальность без учета регистра обеспечивает индекс idx_users_email_lower
+	var userID int
+	err = h.DB.QueryRow("INSERT INTO users (email, password_hash) VALUES ($1, $2) RETURNING id", creds.Email, string(hashedPassword)).Scan(&userID)
 	if err != nil {
 		respondWithError(w, http.StatusConflict, "Email already exists")
 		return
 	}
-	respondWithJSON(w, http.StatusCreated, map[string]string{"message": "User registered successfully"})
+	h.recordAudit(r, audit.Event{Type: audit.Registered, ActorID: userID, TargetType: audit.TargetUser, TargetID: userTarget(userID)})
+	if err := h.sendVerificationEmail(userID, creds.Email); err != nil {
+		// Регистрацию не откатываем: письмо можно запросить повторно через /verify-email/resend
+		log.Printf("RegisterUser: failed to send verification email to user %d: %v", userID, err)
+	}
+	if creds.GuestToken != "" {
+		// Неудачный перенос не мешает регистрации: гость сможет слить прогресс после входа
+		if guestID, err := h.guestFromToken(creds.GuestToken); err != nil {
+			log.Printf("RegisterUser: guest token rejected for user %d: %v", userID, err)
+		} else if _, err := h.mergeGuest(guestID, userID); err != nil {
+			log.Printf("RegisterUser: failed to merge guest %d into user %d: %v", guestID, userID, err)
+		}
+	}
+	respondWithJSON(w, http.StatusCreated, map[string]string{"message": "User registered successfully. Please confirm your email"})
 }
 
 func (h *ApiHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
@@ -62,11 +136,13 @@ func (h *ApiHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
-	var storedPasswordHash string
+	var storedPasswordHash, role string
 	var userID int
-	err := h.DB.QueryRow("SELECT id, password_hash FROM users WHERE email = $1", creds.Email).Scan(&userID, &storedPasswordHash)
+	var emailVerified, totpEnabled bool
+	err := h.DB.QueryRow("SELECT id, password_hash, role, email_verified_at IS NOT NULL, totp_enabled_at IS NOT NULL FROM users WHERE LOWER(email) = $1", normalizeEmail(creds.Email)).Scan(&userID, &storedPasswordHash, &role, &emailVerified, &totpEnabled)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			h.recordAudit(r, audit.Event{Type: audit.LoginFailed, Data: map[string]interface{}{"reason": "unknown_email", "email": normalizeEmail(creds.Email)}})
 			respondWithError(w, http.StatusUnauthorized, "Invalid email or password")
 		} else {
 			respondWithError(w, http.StatusInternalServerError, "Database error")
@@ -75,23 +151,26 @@ func (h *ApiHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 	err = bcrypt.CompareHashAndPassword([]byte(storedPasswordHash), []byte(creds.Password))
 	if err != nil {
+		h.recordAudit(r, audit.Event{Type: audit.LoginFailed, TargetType: audit.TargetUser, TargetID: userTarget(userID), Data: map[string]interface{}{"reason": "bad_password"}})
 		respondWithError(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
-	expirationTime := time.Now().Add(72 * time.Hour)
-	claims := &Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
+	if !emailVerified && UnverifiedRestrictions[ActionLogin] {
+		h.recordAudit(r, audit.Event{Type: audit.LoginFailed, TargetType: audit.TargetUser, TargetID: userTarget(userID), Data: map[string]interface{}{"reason": "email_not_verified"}})
+		respondWithError(w, http.StatusForbidden, "Email not verified")
+		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(JwtKey)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+	if totpEnabled {
+		// Пароль верный, но нужен второй шаг: код из приложения-аутентификатора (/api/login/2fa)
+		challenge, err := issueToken(&Claims{UserID: userID, Purpose: PurposeMFAChallenge}, mfaChallengeTTL)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{"mfa_required": true, "challenge_token": challenge})
 		return
 	}
-	respondWithJSON(w, http.StatusOK, map[string]string{"token": tokenString})
+	h.respondWithSession(w, r, userID, role, false)
 }
 
 type SaveProgressRequest struct {
@@ -99,9 +178,8 @@ type SaveProgressRequest struct {
 	IsCorrect  bool `json:"is_correct"`
 }
 type ExplainErrorRequest struct {
-	PromptRU     string `json:"prompt_ru"`
-	CorrectEN    string `json:"correct_en"`
-	UserAnswerEN string `json:"user_answer_en"`
+	SentenceID int    `json:"sentence_id"`
+	UserAnswer string `json:"user_answer"`
 }
 type hfMessage struct { Role string `json:"role"`; Content string `json:"content"` }
 type hfChatRequest struct { Model string `json:"model"`; Messages []hfMessage `json:"messages"`; Stream bool `json:"stream"` }
@@ -115,6 +193,23 @@ func (h *ApiHandler) SaveProgress(w http.ResponseWriter, r *http.Request) {
 
 	var req SaveProgressRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { respondWithError(w, http.StatusBadRequest, "Invalid payload"); return }
+	if visible, err := h.lessonVisible(0, req.SentenceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	} else if !visible {
+		respondWithError(w, http.StatusNotFound, "Sentence not found")
+		return
+	}
+	if role, _ := r.Context().Value(ContextRoleKey).(string); role == RoleGuest {
+		if free, err := h.lessonAvailableToGuest(0, req.SentenceID); err != nil {
+			log.Printf("SaveProgress: guest access to sentence %d: %v", req.SentenceID, err)
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		} else if !free {
+			respondWithError(w, http.StatusForbidden, "Sign up to unlock this lesson")
+			return
+		}
+	}
 
 	var nextStatus string
 	var nextStreak int
@@ -166,33 +261,58 @@ func (h *ApiHandler) SaveProgress(w http.ResponseWriter, r *http.Request) {
 	_, err = h.DB.Exec(sqlStats, incrementCorrect, userID)
 	if err != nil { log.Printf("Error updating user stats: %v", err) }
 
+	_, err = h.DB.Exec("INSERT INTO user_attempts (user_id, sentence_id, is_correct) VALUES ($1, $2, $3)", userID, req.SentenceID, req.IsCorrect)
+	if err != nil { log.Printf("Error saving attempt history: %v", err) }
+
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Progress saved"})
 }
 
+// GetProgress (GET /api/progress) - весь прогресс пользователя списком, удобно для интеграций.
+func (h *ApiHandler) GetProgress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	progress, err := h.loadProgress(userID)
+	if err != nil {
+		log.Printf("GetProgress: user %d: %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, progress)
+}
+
 // --- GetLevels ---
 func (h *ApiHandler) GetLevels(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(ContextUserIDKey).(int)
 	if !ok { respondWithError(w, http.StatusUnauthorized, "Invalid token"); return }
 
-	rows, err := h.DB.Query("SELECT id, title FROM levels ORDER BY title")
+	// Уровни и счетчики уроков - только активного курса пользователя
+	course, err := h.loadActiveCourse(userID)
+	if err != nil { log.Printf("GetLevels: active course of user %d: %v", userID, err); http.Error(w, "Failed to query levels", http.StatusInternalServerError); return }
+	courseLessonSQL := "l.level_id IN (SELECT id FROM levels WHERE course_id = " + strconv.Itoa(course.ID) + ") AND " + publishedLessonSQL
+
+	// Уровень виден, если в нем есть хотя бы один опубликованный урок
+	rows, err := h.DB.Query("SELECT lv.id, lv.course_id, lv.title, COALESCE(lv.description, '') FROM levels lv WHERE lv.course_id = $1 AND EXISTS (SELECT 1 FROM lessons l WHERE l.level_id = lv.id AND " + publishedLessonSQL + ") ORDER BY lv.sort_order, lv.title", course.ID)
 	if err != nil { http.Error(w, "Failed to query levels", http.StatusInternalServerError); return }
 	defer rows.Close()
 	levels := []models.Level{}
 	for rows.Next() {
 		var l models.Level
-		rows.Scan(&l.ID, &l.Title)
+		rows.Scan(&l.ID, &l.CourseID, &l.Title, &l.Description)
 		levels = append(levels, l)
 	}
 
 	var totalLessons int
-	h.DB.QueryRow("SELECT COUNT(id) FROM lessons").Scan(&totalLessons)
+	h.DB.QueryRow("SELECT COUNT(id) FROM lessons l WHERE " + courseLessonSQL).Scan(&totalLessons)
 
 	var completedLessons int
 	completedQuery := `
-		SELECT COUNT(DISTINCT l.id) FROM lessons l WHERE (
-			(SELECT COUNT(id) FROM sentences s WHERE s.lesson_id = l.id) > 0 AND
-			(SELECT COUNT(id) FROM sentences s WHERE s.lesson_id = l.id) = 
-			(SELECT COUNT(DISTINCT s_up.id) FROM sentences s_up JOIN user_progress up ON s_up.id = up.sentence_id WHERE s_up.lesson_id = l.id AND up.user_id = $1 AND up.status = 'mastered')
+		SELECT COUNT(DISTINCT l.id) FROM lessons l WHERE ` + courseLessonSQL + ` AND (
+			(SELECT COUNT(id) FROM sentences s WHERE s.lesson_id = l.id AND s.archived_at IS NULL) > 0 AND
+			(SELECT COUNT(id) FROM sentences s WHERE s.lesson_id = l.id AND s.archived_at IS NULL) = 
+			(SELECT COUNT(DISTINCT s_up.id) FROM sentences s_up JOIN user_progress up ON s_up.id = up.sentence_id WHERE s_up.lesson_id = l.id AND s_up.archived_at IS NULL AND up.user_id = $1 AND up.status = 'mastered')
 		)
 	`
 	h.DB.QueryRow(completedQuery, userID).Scan(&completedLessons)
@@ -226,10 +346,11 @@ func (h *ApiHandler) GetLevels(w http.ResponseWriter, r *http.Request) {
 	starsQuery := `
 		SELECT
 			l.id,
-			(SELECT COUNT(*) FROM sentences WHERE lesson_id = l.id) AS total,
-			(SELECT COUNT(*) FROM sentences s JOIN user_progress up ON s.id = up.sentence_id WHERE s.lesson_id = l.id AND up.user_id = $1 AND up.status = 'mastered') AS completed,
-			(SELECT COUNT(DISTINCT sentence_id) FROM user_progress up JOIN sentences s ON up.sentence_id = s.id WHERE s.lesson_id = l.id AND up.user_id = $1 AND up.mistake_count > 0) AS errors
+			(SELECT COUNT(*) FROM sentences WHERE lesson_id = l.id AND archived_at IS NULL) AS total,
+			(SELECT COUNT(*) FROM sentences s JOIN user_progress up ON s.id = up.sentence_id WHERE s.lesson_id = l.id AND s.archived_at IS NULL AND up.user_id = $1 AND up.status = 'mastered') AS completed,
+			(SELECT COUNT(DISTINCT sentence_id) FROM user_progress up JOIN sentences s ON up.sentence_id = s.id WHERE s.lesson_id = l.id AND s.archived_at IS NULL AND up.user_id = $1 AND up.mistake_count > 0) AS errors
 		FROM lessons l
+		WHERE ` + courseLessonSQL + `
 	`
 	starRows, err := h.DB.Query(starsQuery, userID)
 	var earnedStarsTotal int = 0
@@ -255,7 +376,13 @@ func (h *ApiHandler) GetLevels(w http.ResponseWriter, r *http.Request) {
 	}
 	// ▲▲▲ КОНЕЦ ИСПРАВЛЕНИЙ ▲▲▲
 
+	// PAT без read-progress получает только контент
+	if !tokenAllows(r, ScopeReadProgress) {
+		completedLessons, totalStudyTime, accuracy, earnedStarsTotal = 0, 0, 0, 0
+	}
+
 	data := struct {
+		Course           models.Course  `json:"course"`
 		Levels           []models.Level `json:"levels"`
 		CompletedLessons int            `json:"completed_lessons"`
 		TotalLessons     int            `json:"total_lessons"`
@@ -264,6 +391,7 @@ func (h *ApiHandler) GetLevels(w http.ResponseWriter, r *http.Request) {
 		EarnedStars      int            `json:"earned_stars"` 
 		TotalStars       int            `json:"total_stars"` 
 	}{
+		Course:           course,
 		Levels:           levels,
 		CompletedLessons: completedLessons,
 		TotalLessons:     totalLessons,
@@ -289,19 +417,19 @@ func (h *ApiHandler) GetLessonsByLevel(w http.ResponseWriter, r *http.Request) {
 
 	sqlQuery := `
 		SELECT
-			l.id, l.level_id, l.lesson_number, l.title,
-			(SELECT COUNT(*) FROM sentences WHERE lesson_id = l.id) AS total_sentences,
-			(SELECT COUNT(*) FROM sentences s JOIN user_progress up ON s.id = up.sentence_id WHERE s.lesson_id = l.id AND up.user_id = $1 AND up.status = 'mastered') AS completed_sentences,
+			l.id, l.level_id, l.lesson_number, l.title, COALESCE(l.description, ''), l.grammar_topics,
+			(SELECT COUNT(*) FROM sentences WHERE lesson_id = l.id AND archived_at IS NULL) AS total_sentences,
+			(SELECT COUNT(*) FROM sentences s JOIN user_progress up ON s.id = up.sentence_id WHERE s.lesson_id = l.id AND s.archived_at IS NULL AND up.user_id = $1 AND up.status = 'mastered') AS completed_sentences,
 			
 			-- Ищем предложения, где пользователь допустил ошибку (mistake_count > 0)
 			(SELECT COUNT(DISTINCT sentence_id) 
              FROM user_progress up 
              JOIN sentences s ON up.sentence_id = s.id 
-             WHERE s.lesson_id = l.id AND up.user_id = $1 AND up.mistake_count > 0
+             WHERE s.lesson_id = l.id AND s.archived_at IS NULL AND up.user_id = $1 AND up.mistake_count > 0
             ) AS sentences_with_errors
             
 		FROM lessons l
-		WHERE l.level_id = $2
+		WHERE l.level_id = $2 AND ` + publishedLessonSQL + `
 		ORDER BY l.lesson_number;
 	`
 	
@@ -313,15 +441,19 @@ func (h *ApiHandler) GetLessonsByLevel(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	// PAT без read-progress получает только контент
+	withProgress := tokenAllows(r, ScopeReadProgress)
 	lessons := []models.Lesson{}
 	for rows.Next() {
 		var l models.Lesson
         var sentencesWithErrors int 
+		var grammarTopics []byte
 		
-		if err := rows.Scan(&l.ID, &l.LevelID, &l.LessonNumber, &l.Title, &l.TotalSentences, &l.CompletedSentences, &sentencesWithErrors); err != nil { 
+		if err := rows.Scan(&l.ID, &l.LevelID, &l.LessonNumber, &l.Title, &l.Description, &grammarTopics, &l.TotalSentences, &l.CompletedSentences, &sentencesWithErrors); err != nil { 
 			log.Printf("DB SCAN ERROR: skipping row: %v", err)
 			continue
 		}
+		json.Unmarshal(grammarTopics, &l.GrammarTopics)
         
         l.SentencesWithErrors = sentencesWithErrors 
         isLessonCompleted := (l.TotalSentences > 0 && l.CompletedSentences == l.TotalSentences)
@@ -340,6 +472,9 @@ func (h *ApiHandler) GetLessonsByLevel(w http.ResponseWriter, r *http.Request) {
         } else {
             l.SentencesWithErrors = 0
         }
+		if !withProgress {
+			l.CompletedSentences, l.SentencesWithErrors = 0, 0
+		}
         
 		lessons = append(lessons, l)
 	}
@@ -353,16 +488,39 @@ func (h *ApiHandler) GetSentencesByLesson(w http.ResponseWriter, r *http.Request
 	vars := mux.Vars(r)
 	lessonID, err := strconv.Atoi(vars["lesson_id"])
 	if err != nil { http.Error(w, "Invalid lesson ID", http.StatusBadRequest); return }
+	// Черновики и запланированные уроки ученикам не отдаются
+	if visible, err := h.lessonVisible(lessonID, 0); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	} else if !visible {
+		respondWithError(w, http.StatusNotFound, "Lesson not found")
+		return
+	}
+	if role, _ := r.Context().Value(ContextRoleKey).(string); role == RoleGuest {
+		if free, err := h.lessonAvailableToGuest(lessonID, 0); err != nil {
+			log.Printf("GetSentencesByLesson: guest access to lesson %d: %v", lessonID, err)
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		} else if !free {
+			respondWithError(w, http.StatusForbidden, "Sign up to unlock this lesson")
+			return
+		}
+	}
 
-	sqlQuery := `SELECT s.id, s.lesson_id, s.order_number, s.prompt_ru, s.answer_en, s.transcription, s.audio_path, up.status, up.correct_streak FROM sentences s LEFT JOIN user_progress up ON s.id = up.sentence_id AND up.user_id = $1 WHERE s.lesson_id = $2 ORDER BY s.order_number;`
+	sqlQuery := `SELECT s.id, s.lesson_id, s.order_number, s.prompt, s.answer, s.transcription, s.audio_path, up.status, up.correct_streak FROM sentences s LEFT JOIN user_progress up ON s.id = up.sentence_id AND up.user_id = $1 WHERE s.lesson_id = $2 AND s.archived_at IS NULL ORDER BY s.order_number;`
 	rows, err := h.DB.Query(sqlQuery, userID, lessonID)
 	if err != nil { http.Error(w, "Failed to query sentences", http.StatusInternalServerError); return }
 	defer rows.Close()
 
+	// PAT без read-progress получает только контент
+	withProgress := tokenAllows(r, ScopeReadProgress)
 	sentences := []models.Sentence{}
 	for rows.Next() {
 		var s models.Sentence
-		if err := rows.Scan(&s.ID, &s.LessonID, &s.OrderNumber, &s.PromptRU, &s.AnswerEN, &s.Transcription, &s.AudioPath, &s.Status, &s.CorrectStreak); err != nil { continue }
+		if err := rows.Scan(&s.ID, &s.LessonID, &s.OrderNumber, &s.Prompt, &s.Answer, &s.Transcription, &s.AudioPath, &s.Status, &s.CorrectStreak); err != nil { continue }
+		if !withProgress {
+			s.Status, s.CorrectStreak = sql.NullString{}, sql.NullInt32{}
+		}
 		sentences = append(sentences, s)
 	}
 	respondWithJSON(w, http.StatusOK, sentences)
@@ -372,24 +530,35 @@ func (h *ApiHandler) GetSentencesByLesson(w http.ResponseWriter, r *http.Request
 func (h *ApiHandler) ExplainError(w http.ResponseWriter, r *http.Request) {
     var req ExplainErrorRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil { respondWithError(w, http.StatusBadRequest, "Invalid request"); return }
-	if req.UserAnswerEN == "" { respondWithJSON(w, http.StatusOK, map[string]string{"explanation": "Пустой ответ."}); return }
+	if strings.TrimSpace(req.UserAnswer) == "" { respondWithJSON(w, http.StatusOK, map[string]string{"explanation": "Пустой ответ."}); return }
+
+	// Текст предложения и языки берутся из БД: клиент присылает только ID
+	var prompt, answer, sourceLang, targetLang string
+	err := h.DB.QueryRow(`SELECT s.prompt, s.answer, c.source_language, c.target_language FROM sentences s
+		JOIN lessons l ON l.id = s.lesson_id JOIN levels lv ON lv.id = l.level_id JOIN courses c ON c.id = lv.course_id
+		WHERE s.id = $1 AND s.archived_at IS NULL AND `+publishedLessonSQL, req.SentenceID).Scan(&prompt, &answer, &sourceLang, &targetLang)
+	if errors.Is(err, sql.ErrNoRows) { respondWithError(w, http.StatusNotFound, "Sentence not found"); return }
+	if err != nil { log.Printf("ExplainError: sentence %d: %v", req.SentenceID, err); respondWithError(w, http.StatusInternalServerError, "Database error"); return }
     hfToken := os.Getenv("HUGGINGFACE_TOKEN")
     if hfToken == "" { respondWithError(w, http.StatusInternalServerError, "AI config error"); return }
 
     model := "meta-llama/Meta-Llama-3-8B-Instruct"
     apiURL := "https://router.huggingface.co/v1/chat/completions"
 
-	prompt := fmt.Sprintf(`Ты — репетитор по английскому. Студент не смог правильно перевести предложение.
+	// Инструкция на английском (ее модель понимает лучше всего), объяснение - на родном языке ученика
+	source, target := languageName(sourceLang), languageName(targetLang)
+	instruction := fmt.Sprintf(`You are a %[2]s tutor. A student failed to translate a sentence from %[1]s into %[2]s.
 
-- Русский: "%s"
-- Правильный ответ: "%s"
+- %[1]s: "%[3]s"
+- Correct answer: "%[4]s"
+- Student's answer: "%[5]s"
 
-Твоя задача — ОЧЕНЬ КРАТКО (1-2 предложения) на РУССКОМ языке объяснить, на какое **основное грамматическое правило** нужно обратить внимание в "Правильном ответе".
+VERY BRIEFLY (1-2 sentences), in %[1]s, explain the **main grammar rule** the student should pay attention to in the correct answer.
 
-Начинай:`,
-    req.PromptRU, req.CorrectEN)
+Answer:`,
+		source, target, prompt, answer, req.UserAnswer)
 
-    payload := hfChatRequest{ Model: model, Messages: []hfMessage{ {Role: "user", Content: prompt}, }, Stream: false }
+    payload := hfChatRequest{ Model: model, Messages: []hfMessage{ {Role: "user", Content: instruction}, }, Stream: false }
     payloadBytes, _ := json.Marshal(payload)
     hfReq, _ := http.NewRequestWithContext(r.Context(), "POST", apiURL, bytes.NewBuffer(payloadBytes))
     hfReq.Header.Set("Authorization", "Bearer "+hfToken)
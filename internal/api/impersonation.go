@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+
+	"lingo-sprint/internal/audit"
+)
+
+// Имперсонация: администратор (право PermImpersonate) получает короткоживущий токен
+// от имени ученика, чтобы увидеть ровно то, что видит он. Такой токен помечен claim'ом
+// impersonator_id, каждый запрос по нему пишется в audit_events, а запись
+// (POST/PUT/PATCH/DELETE) запрещена, если ее явно не разрешили при выдаче.
+const (
+	impersonationTTL       = 15 * time.Minute
+	maxImpersonationReason = 500
+)
+
+// ContextImpersonatorKey - ID администратора, если запрос идет от его имени под чужим аккаунтом.
+const ContextImpersonatorKey UserIDKey = "impersonatorID"
+
+type ImpersonateRequest struct {
+	Reason     string `json:"reason"`      // обязательно: номер обращения или пояснение
+	AllowWrite bool   `json:"allow_write"` // разрешить изменяющие запросы (например, воспроизвести сохранение прогресса)
+}
+
+// StartImpersonation (POST /api/admin/users/{user_id}/impersonate) выдает токен от имени пользователя.
+func (h *ApiHandler) StartImpersonation(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	if _, nested := r.Context().Value(ContextImpersonatorKey).(int); nested {
+		respondWithError(w, http.StatusForbidden, "Cannot impersonate from an impersonation session")
+		return
+	}
+	targetID, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	var req ImpersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || utf8.RuneCountInString(req.Reason) > maxImpersonationReason {
+		respondWithError(w, http.StatusBadRequest, "reason must be 1-500 characters")
+		return
+	}
+	if targetID == adminID {
+		respondWithError(w, http.StatusBadRequest, "Cannot impersonate yourself")
+		return
+	}
+
+	var role string
+	var deleted bool
+	err = h.DB.QueryRow("SELECT role, deleted_at IS NOT NULL FROM users WHERE id = $1", targetID).Scan(&role, &deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	// Через имперсонацию нельзя получить чужие повышенные права
+	if role != RoleUser && role != RoleGuest {
+		respondWithError(w, http.StatusForbidden, "Only learner accounts can be impersonated")
+		return
+	}
+	if deleted {
+		respondWithError(w, http.StatusConflict, "User account is deleted")
+		return
+	}
+
+	claims := &Claims{UserID: targetID, Role: role, ImpersonatorID: adminID, AllowWrite: req.AllowWrite}
+	token, err := h.startSession(r, claims, impersonationTTL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ImpersonationStarted, ActorID: adminID, TargetType: audit.TargetUser, TargetID: userTarget(targetID),
+		Data: map[string]interface{}{"session_id": claims.ID, "reason": req.Reason, "allow_write": req.AllowWrite}})
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":         token,
+		"impersonating": targetID,
+		"allow_write":   req.AllowWrite,
+		"expires_at":    time.Now().Add(impersonationTTL),
+	})
+}
+
+// serveAuthenticated передает запрос дальше; для токенов имперсонации запрещает запись
+// (если она не разрешена) и пишет каждый запрос в журнал.
+func (h *ApiHandler) serveAuthenticated(w http.ResponseWriter, r *http.Request, claims *Claims, next http.Handler) {
+	if claims == nil || claims.ImpersonatorID == 0 {
+		next.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("X-Impersonated-By", strconv.Itoa(claims.ImpersonatorID))
+	logRequest := func(status int) {
+		h.recordAudit(r, audit.Event{Type: audit.ImpersonationRequest, ActorID: claims.ImpersonatorID, TargetType: audit.TargetUser, TargetID: userTarget(claims.UserID),
+			Data: map[string]interface{}{"session_id": claims.ID, "method": r.Method, "path": r.URL.Path, "status": status}})
+	}
+
+	if isWriteMethod(r.Method) && !claims.AllowWrite {
+		logRequest(http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "Write actions are not allowed while impersonating")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), ContextImpersonatorKey, claims.ImpersonatorID)
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(rec, r.WithContext(ctx))
+	logRequest(rec.status)
+}
+
+// DenyImpersonatedWrites закрывает запись даже для токенов с allow_write: настройки безопасности
+// аккаунта (пароль, email, 2FA, токены, удаление) меняет только сам пользователь.
+func DenyImpersonatedWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(ContextImpersonatorKey).(int); ok && isWriteMethod(r.Method) {
+			respondWithError(w, http.StatusForbidden, "Account settings cannot be changed while impersonating")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
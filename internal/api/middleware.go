@@ -14,50 +14,95 @@ import (
 type UserIDKey string
 const ContextUserIDKey UserIDKey = "userID"
 
+// ContextRoleKey - роль пользователя из JWT (см. rbac.go).
+const ContextRoleKey UserIDKey = "role"
+
+// ContextSessionIDKey - ID строки в user_sessions (claim "jti").
+const ContextSessionIDKey UserIDKey = "sessionID"
+
+// ContextMFAKey - вход в эту сессию подтвержден вторым фактором (claim "mfa").
+const ContextMFAKey UserIDKey = "mfa"
+
 // AuthMiddleware - наш "охранник"
-func AuthMiddleware(next http.Handler) http.Handler {
+func (h *ApiHandler) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 1. Получаем заголовок "Authorization"
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			respondWithError(w, http.StatusUnauthorized, "Authorization header required")
+		tokenString, ok := bearerToken(w, r)
+		if !ok {
 			return
 		}
-
-		// 2. Проверяем, что он начинается с "Bearer "
-		headerParts := strings.Split(authHeader, " ")
-		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-			respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header format")
+		ctx, claims, ok := h.authenticateJWT(w, r, tokenString)
+		if !ok {
 			return
 		}
+		// 5. Передаем "обогащенный" запрос следующему обработчику (например, GetLevels)
+		h.serveAuthenticated(w, r.WithContext(ctx), claims, next)
+	})
+}
 
-		tokenString := headerParts[1]
-		claims := &Claims{} // Используем структуру Claims из handlers.go
+// bearerToken достает токен из заголовка "Authorization: Bearer ...". При ошибке сам пишет 401.
+func bearerToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	// 1. Получаем заголовок "Authorization"
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		respondWithError(w, http.StatusUnauthorized, "Authorization header required")
+		return "", false
+	}
 
-		// 3. Парсим токен, используя наш секретный ключ (jwtKey из handlers.go)
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return JwtKey, nil
-		})
+	// 2. Проверяем, что он начинается с "Bearer "
+	headerParts := strings.Split(authHeader, " ")
+	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header format")
+		return "", false
+	}
+	return headerParts[1], true
+}
 
-		if err != nil {
-			if errors.Is(err, jwt.ErrTokenExpired) { // <-- Теперь 'errors' определен
-				respondWithError(w, http.StatusUnauthorized, "Token has expired")
-			} else {
-				respondWithError(w, http.StatusUnauthorized, "Invalid token")
-			}
-			return
-		}
+// authenticateJWT проверяет JWT сессии и возвращает контекст с ID пользователя, ролью и сессией, а также сами claims.
+// При ошибке сам пишет ответ и возвращает false.
+func (h *ApiHandler) authenticateJWT(w http.ResponseWriter, r *http.Request, tokenString string) (context.Context, *Claims, bool) {
+	claims := &Claims{} // Используем структуру Claims из handlers.go
 
-		if !token.Valid {
+	// 3. Парсим токен, используя наш секретный ключ (jwtKey из handlers.go)
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return JwtKey, nil
+	})
+
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) { // <-- Теперь 'errors' определен
+			respondWithError(w, http.StatusUnauthorized, "Token has expired")
+		} else {
 			respondWithError(w, http.StatusUnauthorized, "Invalid token")
-			return
 		}
+		return nil, nil, false
+	}
 
-		// 4. УСПЕХ! Токен валидный. "Прикрепляем" ID пользователя к запросу.
-		// Мы "обогащаем" запрос, добавляя в его "контекст" ID пользователя.
-		ctx := context.WithValue(r.Context(), ContextUserIDKey, claims.UserID)
-		
-		// 5. Передаем "обогащенный" запрос следующему обработчику (например, GetLevels)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
\ No newline at end of file
+	if !token.Valid {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return nil, nil, false
+	}
+
+	// Служебные токены (например, challenge второго шага входа) не дают доступа к API
+	if claims.Purpose != "" {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return nil, nil, false
+	}
+
+	// Сессия могла быть отозвана (смена пароля, удаление аккаунта и т.п.)
+	active, err := h.isSessionActive(claims.ID, claims.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return nil, nil, false
+	}
+	if !active {
+		respondWithError(w, http.StatusUnauthorized, "Session has been revoked")
+		return nil, nil, false
+	}
+
+	// 4. УСПЕХ! Токен валидный. "Прикрепляем" ID пользователя к запросу.
+	// Мы "обогащаем" запрос, добавляя в его "контекст" ID пользователя.
+	ctx := context.WithValue(r.Context(), ContextUserIDKey, claims.UserID)
+	ctx = context.WithValue(ctx, ContextRoleKey, claims.Role)
+	ctx = context.WithValue(ctx, ContextSessionIDKey, claims.ID)
+	ctx = context.WithValue(ctx, ContextMFAKey, claims.MFA)
+	return ctx, claims, true
+}
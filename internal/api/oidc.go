@@ -0,0 +1,281 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+
+	"lingo-sprint/internal/audit"
+	"lingo-sprint/internal/oidc"
+)
+
+const (
+	oidcStateTTL    = 10 * time.Minute
+	oidcStateCookie = "oidc_state"
+)
+
+type AuthProvider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	StartURL    string `json:"start_url"`
+}
+
+// ListAuthProviders возвращает внешних провайдеров входа для кнопок на лендинге.
+func (h *ApiHandler) ListAuthProviders(w http.ResponseWriter, r *http.Request) {
+	providers := []AuthProvider{}
+	for name, p := range h.OIDCProviders {
+		providers = append(providers, AuthProvider{
+			Name:        name,
+			DisplayName: p.Config.DisplayName,
+			StartURL:    "/api/auth/oidc/" + name + "/start",
+		})
+	}
+	respondWithJSON(w, http.StatusOK, providers)
+}
+
+func oidcRedirectURI(provider string) string {
+	return AppBaseURL + "/api/auth/oidc/" + provider + "/callback"
+}
+
+// StartOIDCLogin отправляет браузер на страницу входа провайдера.
+// state, nonce и code_verifier живут в oidc_login_states, state дополнительно
+// кладется в cookie, чтобы callback нельзя было "подсунуть" чужому браузеру.
+func (h *ApiHandler) StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["provider"]
+	provider, ok := h.OIDCProviders[name]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Unknown provider")
+		return
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), oidcRedirectURI(name), state, nonce, challenge)
+	if err != nil {
+		log.Printf("StartOIDCLogin(%s): %v", name, err)
+		respondWithError(w, http.StatusBadGateway, "Identity provider is unavailable")
+		return
+	}
+
+	_, err = h.DB.Exec("INSERT INTO oidc_login_states (state, provider, code_verifier, nonce, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		hashToken(state), name, verifier, nonce, time.Now().Add(oidcStateTTL))
+	if err != nil {
+		log.Printf("StartOIDCLogin(%s): failed to store state: %v", name, err)
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if _, err := h.DB.Exec("DELETE FROM oidc_login_states WHERE expires_at < NOW()"); err != nil {
+		log.Printf("StartOIDCLogin: failed to purge expired states: %v", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc/",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback завершает вход: проверяет state, меняет code на id_token и
+// отдает токен сессии лендингу во fragment'е URL (он не уходит на сервер и в логи).
+func (h *ApiHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["provider"]
+	provider, ok := h.OIDCProviders[name]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Unknown provider")
+		return
+	}
+	fail := func(reason string) {
+		http.Redirect(w, r, "/#"+url.Values{"oidc_error": {reason}}.Encode(), http.StatusSeeOther)
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Value: "", Path: "/api/auth/oidc/", MaxAge: -1})
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		fail(errParam)
+		return
+	}
+	state := r.URL.Query().Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if state == "" || err != nil || cookie.Value != state {
+		fail("state_mismatch")
+		return
+	}
+
+	var verifier, nonce string
+	err = h.DB.QueryRow("DELETE FROM oidc_login_states WHERE state = $1 AND provider = $2 AND expires_at > NOW() RETURNING code_verifier, nonce",
+		hashToken(state), name).Scan(&verifier, &nonce)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("OIDCCallback(%s): state lookup failed: %v", name, err)
+		}
+		fail("state_expired")
+		return
+	}
+
+	identity, err := provider.Exchange(r.Context(), r.URL.Query().Get("code"), oidcRedirectURI(name), verifier, nonce)
+	if err != nil {
+		log.Printf("OIDCCallback(%s): %v", name, err)
+		fail("provider_error")
+		return
+	}
+
+	userID, email, err := h.resolveIdentity(r, identity)
+	if err != nil {
+		log.Printf("OIDCCallback(%s): %v", name, err)
+		fail("link_failed")
+		return
+	}
+
+	var totpEnabled bool
+	var role string
+	if err := h.DB.QueryRow("SELECT totp_enabled_at IS NOT NULL, role FROM users WHERE id = $1", userID).Scan(&totpEnabled, &role); err != nil {
+		fail("link_failed")
+		return
+	}
+	fragment := url.Values{"email": {email}}
+	if totpEnabled {
+		// Внешний вход заменяет только пароль, второй фактор остается обязательным
+		challenge, err := issueToken(&Claims{UserID: userID, Purpose: PurposeMFAChallenge}, mfaChallengeTTL)
+		if err != nil {
+			fail("token_error")
+			return
+		}
+		fragment.Set("mfa_challenge", challenge)
+	} else {
+		if err := h.restoreIfDeleted(r, userID); err != nil {
+			fail("link_failed")
+			return
+		}
+		token, err := h.startSession(r, &Claims{UserID: userID, Role: role}, sessionTTL)
+		if err != nil {
+			fail("token_error")
+			return
+		}
+		h.recordAudit(r, audit.Event{Type: audit.LoginSucceeded, ActorID: userID, TargetType: audit.TargetUser, TargetID: userTarget(userID),
+			Data: map[string]interface{}{"method": "oidc", "provider": name}})
+		fragment.Set("token", token)
+	}
+	http.Redirect(w, r, "/#"+fragment.Encode(), http.StatusSeeOther)
+}
+
+var errEmailNotVerified = errors.New("provider did not confirm the email address")
+
+// resolveIdentity находит пользователя по внешней личности, привязывает ее к
+// существующему аккаунту по подтвержденному email или создает новый аккаунт.
+func (h *ApiHandler) resolveIdentity(r *http.Request, id *oidc.Identity) (int, string, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var email string
+	err = tx.QueryRow(`SELECT u.id, u.email FROM user_identities i JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2`, id.Provider, id.Subject).Scan(&userID, &email)
+	if err == nil {
+		if _, err := tx.Exec("UPDATE user_identities SET last_login_at = NOW() WHERE provider = $1 AND subject = $2", id.Provider, id.Subject); err != nil {
+			return 0, "", err
+		}
+		return userID, email, tx.Commit()
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, "", err
+	}
+
+	// Новая личность: привязываем только по email, который провайдер подтвердил
+	email = normalizeEmail(id.Email)
+	if !id.EmailVerified || validateEmail(email) != nil {
+		return 0, "", errEmailNotVerified
+	}
+
+	var verified, passwordReset bool
+	err = tx.QueryRow("SELECT id, email_verified_at IS NOT NULL FROM users WHERE LOWER(email) = $1 FOR UPDATE", email).Scan(&userID, &verified)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Аккаунта нет - создаем. Пароль случайный: войти можно только через провайдера,
+		// а пароль при желании задается потом (ChangePassword без текущего пароля).
+		hash, err := unusablePasswordHash()
+		if err != nil {
+			return 0, "", err
+		}
+		err = tx.QueryRow("INSERT INTO users (email, password_hash, has_password, email_verified_at) VALUES ($1, $2, FALSE, NOW()) RETURNING id", email, hash).Scan(&userID)
+		if err != nil {
+			return 0, "", err
+		}
+	case err != nil:
+		return 0, "", err
+	case !verified:
+		// Локальный аккаунт с неподтвержденным email мог зарегистрировать кто угодно.
+		// Владелец адреса теперь доказан провайдером, поэтому все, чем мог войти прежний
+		// регистрант, отбирается: пароль, сессии, персональные токены и его 2FA (иначе
+		// настоящий владелец получил бы запрос кода, который ему не пройти).
+		hash, err := unusablePasswordHash()
+		if err != nil {
+			return 0, "", err
+		}
+		if _, err := tx.Exec(`UPDATE users SET email_verified_at = NOW(), password_hash = $1, has_password = FALSE,
+			totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = $2`, hash, userID); err != nil {
+			return 0, "", err
+		}
+		if _, err := tx.Exec("DELETE FROM totp_recovery_codes WHERE user_id = $1", userID); err != nil {
+			return 0, "", err
+		}
+		if _, err := tx.Exec("DELETE FROM email_change_requests WHERE user_id = $1 AND used_at IS NULL", userID); err != nil {
+			return 0, "", err
+		}
+		if err := revokeOtherSessions(tx, userID, ""); err != nil {
+			return 0, "", err
+		}
+		if err := revokeAllTokens(tx, userID); err != nil {
+			return 0, "", err
+		}
+		passwordReset = true
+	}
+
+	_, err = tx.Exec("INSERT INTO user_identities (user_id, provider, subject, email, last_login_at) VALUES ($1, $2, $3, $4, NOW())",
+		userID, id.Provider, id.Subject, email)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, "", err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.IdentityLinked, ActorID: userID, TargetType: audit.TargetUser, TargetID: userTarget(userID),
+		Data: map[string]interface{}{"provider": id.Provider, "password_reset": passwordReset}})
+	return userID, email, nil
+}
+
+// unusablePasswordHash - bcrypt от случайной строки, которую никто не знает.
+func unusablePasswordHash() (string, error) {
+	secret, _, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	return string(hash), err
+}
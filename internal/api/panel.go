@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"lingo-sprint/internal/audit"
+)
+
+// Вход в админ-панель (/admin, internal/admin) идет через обычную сессию приложения:
+// администратор, уже вошедший в приложение (с паролем и 2FA), получает токен на минуту
+// и обменивает его на сессию панели. Своих паролей у панели нет.
+const (
+	PurposeAdminPanel = "admin_panel"
+	panelTokenTTL     = time.Minute
+)
+
+var errInvalidPanelToken = errors.New("invalid admin panel token")
+
+// IssuePanelToken (POST /api/admin/panel-token) выдает токен для входа в админ-панель.
+func (h *ApiHandler) IssuePanelToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	role, _ := r.Context().Value(ContextRoleKey).(string)
+	sessionID, _ := r.Context().Value(ContextSessionIDKey).(string)
+	mfa, _ := r.Context().Value(ContextMFAKey).(bool)
+	// ID сессии в токене: выход из приложения (отзыв сессии) закрывает и вход в панель
+	claims := &Claims{UserID: userID, Role: role, Purpose: PurposeAdminPanel, MFA: mfa}
+	claims.ID = sessionID
+	token, err := issueToken(claims, panelTokenTTL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{"token": token, "expires_at": time.Now().Add(panelTokenTTL)})
+}
+
+// ParsePanelToken проверяет токен входа в панель и возвращает ID пользователя.
+// Роль здесь не проверяется: ее по БД проверяет сама панель.
+func (h *ApiHandler) ParsePanelToken(tokenString string) (int, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return JwtKey, nil
+	})
+	if err != nil || !token.Valid || claims.Purpose != PurposeAdminPanel {
+		return 0, errInvalidPanelToken
+	}
+	// Панель - только для администраторов, и без 2FA в нее не пускаем так же, как в /api/admin
+	if MFARequiredRoles[RoleAdmin] && !claims.MFA {
+		return 0, errInvalidPanelToken
+	}
+	active, err := h.isSessionActive(claims.ID, claims.UserID)
+	if err != nil {
+		return 0, err
+	}
+	if !active {
+		return 0, errInvalidPanelToken
+	}
+	return claims.UserID, nil
+}
+
+// RecordAudit - recordAudit для админ-панели, которая обрабатывает запросы вне этого пакета.
+func (h *ApiHandler) RecordAudit(r *http.Request, e audit.Event) {
+	h.recordAudit(r, e)
+}
@@ -0,0 +1,309 @@
+package api
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"lingo-sprint/internal/audit"
+)
+
+type ExportProgress struct {
+	SentenceID     int       `json:"sentence_id"`
+	LessonID       int       `json:"lesson_id"`
+	Prompt         string    `json:"prompt"`
+	Answer         string    `json:"answer"`
+	Status         string    `json:"status"`
+	CorrectStreak  int       `json:"correct_streak"`
+	MistakeCount   int       `json:"mistake_count"`
+	NextReviewDate time.Time `json:"next_review_date"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ExportAttempt struct {
+	SentenceID int       `json:"sentence_id"`
+	IsCorrect  bool      `json:"is_correct"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ExportIdentity struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportSession struct {
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	IP        string     `json:"ip"`
+	UserAgent string     `json:"user_agent"`
+}
+
+// DataExport - все, что мы храним о пользователе (кроме хешей паролей и секретов).
+type DataExport struct {
+	ExportedAt time.Time        `json:"exported_at"`
+	Account    *MeResponse      `json:"account"`
+	Progress   []ExportProgress `json:"progress"`
+	Attempts   []ExportAttempt  `json:"attempts"`
+	Identities []ExportIdentity `json:"identities"`
+	Sessions   []ExportSession  `json:"sessions"`
+}
+
+// loadProgress - весь прогресс пользователя вместе с текстом предложений.
+func (h *ApiHandler) loadProgress(userID int) ([]ExportProgress, error) {
+	rows, err := h.DB.Query(`SELECT s.id, s.lesson_id, s.prompt, s.answer, up.status, up.correct_streak,
+			up.mistake_count, up.next_review_date, up.updated_at
+		FROM user_progress up JOIN sentences s ON s.id = up.sentence_id
+		WHERE up.user_id = $1 ORDER BY s.lesson_id, s.order_number`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	progress := []ExportProgress{}
+	for rows.Next() {
+		var p ExportProgress
+		if err := rows.Scan(&p.SentenceID, &p.LessonID, &p.Prompt, &p.Answer, &p.Status, &p.CorrectStreak,
+			&p.MistakeCount, &p.NextReviewDate, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+	return progress, rows.Err()
+}
+
+func (h *ApiHandler) collectExport(userID int) (*DataExport, error) {
+	account, err := h.buildMe(userID)
+	if err != nil {
+		return nil, err
+	}
+	export := &DataExport{
+		ExportedAt: time.Now().UTC(),
+		Account:    account,
+		Attempts:   []ExportAttempt{},
+		Identities: []ExportIdentity{},
+		Sessions:   []ExportSession{},
+	}
+
+	if export.Progress, err = h.loadProgress(userID); err != nil {
+		return nil, err
+	}
+
+	attemptRows, err := h.DB.Query("SELECT sentence_id, is_correct, created_at FROM user_attempts WHERE user_id = $1 ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer attemptRows.Close()
+	for attemptRows.Next() {
+		var a ExportAttempt
+		if err := attemptRows.Scan(&a.SentenceID, &a.IsCorrect, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		export.Attempts = append(export.Attempts, a)
+	}
+	if err := attemptRows.Err(); err != nil {
+		return nil, err
+	}
+
+	identityRows, err := h.DB.Query("SELECT provider, COALESCE(email, ''), created_at FROM user_identities WHERE user_id = $1 ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer identityRows.Close()
+	for identityRows.Next() {
+		var i ExportIdentity
+		if err := identityRows.Scan(&i.Provider, &i.Email, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		export.Identities = append(export.Identities, i)
+	}
+	if err := identityRows.Err(); err != nil {
+		return nil, err
+	}
+
+	sessionRows, err := h.DB.Query("SELECT created_at, revoked_at, COALESCE(ip, ''), COALESCE(user_agent, '') FROM user_sessions WHERE user_id = $1 ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer sessionRows.Close()
+	for sessionRows.Next() {
+		var s ExportSession
+		if err := sessionRows.Scan(&s.CreatedAt, &s.RevokedAt, &s.IP, &s.UserAgent); err != nil {
+			return nil, err
+		}
+		export.Sessions = append(export.Sessions, s)
+	}
+	return export, sessionRows.Err()
+}
+
+// ExportMyData (GET /api/me/export) отдает все данные пользователя: JSON по умолчанию, ZIP при ?format=zip.
+func (h *ApiHandler) ExportMyData(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	export, err := h.collectExport(userID)
+	if err != nil {
+		log.Printf("ExportMyData: user %d: %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to export data")
+		return
+	}
+
+	filename := fmt.Sprintf("lingo-sprint-export-%d-%s", userID, export.ExportedAt.Format("20060102"))
+	if r.URL.Query().Get("format") != "zip" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		respondWithJSON(w, http.StatusOK, export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"account.json", export.Account},
+		{"progress.json", export.Progress},
+		{"attempts.json", export.Attempts},
+		{"identities.json", export.Identities},
+		{"sessions.json", export.Sessions},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			log.Printf("ExportMyData: zip %s: %v", f.name, err)
+			return
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			log.Printf("ExportMyData: zip %s: %v", f.name, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("ExportMyData: zip close: %v", err)
+	}
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// DeleteMe (DELETE /api/me) помечает аккаунт удаленным и отзывает все сессии.
+// В течение AccountDeletionGrace аккаунт можно восстановить, просто войдя в него;
+// потом PurgeDeletedAccounts удаляет его окончательно.
+func (h *ApiHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	var req DeleteAccountRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+
+	// Удалять аккаунт без пароля можно только из "свежей" сессии (нужно для входа через OIDC,
+	// где пароля у пользователя нет)
+	if req.Password != "" {
+		if !h.checkCurrentPassword(w, r, userID, req.Password) {
+			return
+		}
+	} else if recent, err := h.isRecentLogin(r); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	} else if !recent {
+		respondWithError(w, http.StatusForbidden, "Password or a recent login is required")
+		return
+	}
+
+	purgeAfter := time.Now().Add(AccountDeletionGrace)
+	tx, err := h.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE users SET deleted_at = NOW(), purge_after = $1 WHERE id = $2", purgeAfter, userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if err := revokeOtherSessions(tx, userID, ""); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	h.recordAudit(r, audit.Event{Type: audit.AccountDeleted, TargetType: audit.TargetUser, TargetID: userTarget(userID),
+		Data: map[string]interface{}{"purge_after": purgeAfter}})
+	respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+		"message":     "Account scheduled for deletion. Log in before the purge date to restore it",
+		"purge_after": purgeAfter,
+	})
+}
+
+// restoreIfDeleted отменяет удаление, если пользователь вошел в течение льготного периода.
+func (h *ApiHandler) restoreIfDeleted(r *http.Request, userID int) error {
+	res, err := h.DB.Exec("UPDATE users SET deleted_at = NULL, purge_after = NULL WHERE id = $1 AND deleted_at IS NOT NULL", userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		h.recordAudit(r, audit.Event{Type: audit.AccountRestored, ActorID: userID, TargetType: audit.TargetUser, TargetID: userTarget(userID)})
+	}
+	return nil
+}
+
+// PurgeDeletedAccounts окончательно удаляет аккаунты, у которых истек льготный период.
+// Прогресс, попытки, сессии и прочее удаляются каскадом (ON DELETE CASCADE).
+func (h *ApiHandler) PurgeDeletedAccounts() (int, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("DELETE FROM users WHERE deleted_at IS NOT NULL AND purge_after <= NOW() RETURNING id, email")
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	var emails []string
+	for rows.Next() {
+		var id int
+		var email string
+		if err := rows.Scan(&id, &email); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		emails = append(emails, email)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, email := range emails {
+		if _, err := tx.Exec("DELETE FROM auth_throttle WHERE key = $1", "email:"+normalizeEmail(email)); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		h.recordAudit(nil, audit.Event{Type: audit.AccountPurged, TargetType: audit.TargetUser, TargetID: userTarget(id)})
+	}
+	return len(ids), nil
+}
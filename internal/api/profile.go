@@ -0,0 +1,278 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	_ "time/tzdata" // в alpine-образе нет /usr/share/zoneinfo
+	"unicode/utf8"
+
+	"lingo-sprint/internal/models"
+)
+
+var (
+	languageCodeRe = regexp.MustCompile(`^[a-z]{2,3}$`)                             // ISO 639-1/639-3: "ru", "en", "uk"
+	localeRe       = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)                 // "ru", "en-US"
+	ttsVoiceRe     = regexp.MustCompile(`^[a-z]{2,3}-[A-Z]{2}-[A-Za-z0-9-]{1,48}$`) // "en-US-Standard-F"
+)
+
+const (
+	maxDisplayNameLen = 50
+	maxDailyGoal      = 500
+)
+
+// defaultProfile - настройки пользователя, который еще ничего не менял.
+func defaultProfile() models.UserProfile {
+	return models.UserProfile{
+		NativeLanguage:    "ru",
+		TargetLanguage:    "en",
+		TimeZone:          "UTC",
+		DailyGoal:         20,
+		PreferredTTSVoice: "en-US-Standard-F", // тот же голос, что в scripts/audio_generator
+		UILocale:          "ru",
+		Notifications:     models.NotificationPrefs{DailyReminder: true, WeeklyReport: true},
+	}
+}
+
+func validateProfile(p models.UserProfile) error {
+	if utf8.RuneCountInString(p.DisplayName) > maxDisplayNameLen {
+		return fmt.Errorf("display_name must be at most %d characters", maxDisplayNameLen)
+	}
+	if !languageCodeRe.MatchString(p.NativeLanguage) {
+		return errors.New("native_language must be an ISO 639 code")
+	}
+	if !languageCodeRe.MatchString(p.TargetLanguage) {
+		return errors.New("target_language must be an ISO 639 code")
+	}
+	if p.NativeLanguage == p.TargetLanguage {
+		return errors.New("native_language and target_language must differ")
+	}
+	if _, err := time.LoadLocation(p.TimeZone); err != nil || p.TimeZone == "" || p.TimeZone == "Local" {
+		return errors.New("time_zone must be an IANA time zone, e.g. Europe/Moscow")
+	}
+	if p.DailyGoal < 1 || p.DailyGoal > maxDailyGoal {
+		return fmt.Errorf("daily_goal must be between 1 and %d", maxDailyGoal)
+	}
+	if !ttsVoiceRe.MatchString(p.PreferredTTSVoice) {
+		return errors.New("preferred_tts_voice must look like en-US-Standard-F")
+	}
+	if !localeRe.MatchString(p.UILocale) {
+		return errors.New("ui_locale must look like ru or en-US")
+	}
+	return nil
+}
+
+// loadProfile читает профиль; если строки в user_profiles еще нет, возвращает значения по умолчанию.
+func (h *ApiHandler) loadProfile(userID int) (models.UserProfile, error) {
+	p := defaultProfile()
+	var notifications []byte
+	err := h.DB.QueryRow(`SELECT display_name, native_language, target_language, time_zone, daily_goal,
+			preferred_tts_voice, ui_locale, notification_prefs, active_course_id
+		FROM user_profiles WHERE user_id = $1`, userID).Scan(
+		&p.DisplayName, &p.NativeLanguage, &p.TargetLanguage, &p.TimeZone, &p.DailyGoal,
+		&p.PreferredTTSVoice, &p.UILocale, &notifications, &p.ActiveCourseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(notifications, &p.Notifications); err != nil {
+		log.Printf("loadProfile: bad notification_prefs for user %d: %v", userID, err)
+	}
+	return p, nil
+}
+
+type UserStats struct {
+	TotalAttempts     int     `json:"total_attempts"`
+	TotalCorrect      int     `json:"total_correct"`
+	Accuracy          float64 `json:"accuracy"`
+	MasteredSentences int     `json:"mastered_sentences"`
+}
+
+type Entitlement struct {
+	SubscriptionStatus string `json:"subscription_status"` // 'free', 'premium'
+	IsPremium          bool   `json:"is_premium"`
+}
+
+type MeResponse struct {
+	ID            int                `json:"id"`
+	Email         string             `json:"email"`
+	EmailVerified bool               `json:"email_verified"`
+	Role          string             `json:"role"`
+	MFAEnabled    bool               `json:"mfa_enabled"`
+	HasPassword   bool               `json:"has_password"` // false - вход через OIDC, current_password не спрашивать
+	Profile       models.UserProfile `json:"profile"`
+	Course        models.Course      `json:"course"` // активный курс (profile.active_course_id или курс по умолчанию)
+	Stats         UserStats          `json:"stats"`
+	Entitlement   Entitlement        `json:"entitlement"`
+}
+
+func (h *ApiHandler) buildMe(userID int) (*MeResponse, error) {
+	me := &MeResponse{ID: userID}
+	err := h.DB.QueryRow(`SELECT email, email_verified_at IS NOT NULL, role, totp_enabled_at IS NOT NULL, has_password,
+			subscription_status, COALESCE(total_attempts, 0), COALESCE(total_correct, 0),
+			(SELECT COUNT(*) FROM user_progress WHERE user_id = users.id AND status = 'mastered')
+		FROM users WHERE id = $1`, userID).Scan(
+		&me.Email, &me.EmailVerified, &me.Role, &me.MFAEnabled, &me.HasPassword,
+		&me.Entitlement.SubscriptionStatus, &me.Stats.TotalAttempts, &me.Stats.TotalCorrect, &me.Stats.MasteredSentences)
+	if err != nil {
+		return nil, err
+	}
+	me.Entitlement.IsPremium = me.Entitlement.SubscriptionStatus == "premium"
+	if me.Stats.TotalAttempts > 0 {
+		me.Stats.Accuracy = float64(me.Stats.TotalCorrect) / float64(me.Stats.TotalAttempts) * 100
+	}
+	if me.Profile, err = h.loadProfile(userID); err != nil {
+		return nil, err
+	}
+	if me.Course, err = h.loadActiveCourse(userID); err != nil {
+		return nil, err
+	}
+	return me, nil
+}
+
+// GetMe возвращает профиль вместе со статистикой и подпиской.
+func (h *ApiHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	me, err := h.buildMe(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		log.Printf("GetMe: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, me)
+}
+
+// UpdateProfileRequest - частичное обновление: nil-поля не трогаем.
+type UpdateProfileRequest struct {
+	DisplayName       *string `json:"display_name"`
+	NativeLanguage    *string `json:"native_language"`
+	TargetLanguage    *string `json:"target_language"`
+	TimeZone          *string `json:"time_zone"`
+	DailyGoal         *int    `json:"daily_goal"`
+	PreferredTTSVoice *string `json:"preferred_tts_voice"`
+	UILocale          *string `json:"ui_locale"`
+	ActiveCourseID    *int    `json:"active_course_id"` // 0 - курс по умолчанию
+	Notifications     *struct {
+		DailyReminder *bool `json:"daily_reminder"`
+		WeeklyReport  *bool `json:"weekly_report"`
+		ProductNews   *bool `json:"product_news"`
+	} `json:"notifications"`
+}
+
+// UpdateMe (PATCH /api/me) меняет настройки профиля.
+func (h *ApiHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	var req UpdateProfileRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	p, err := h.loadProfile(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if req.DisplayName != nil {
+		p.DisplayName = strings.TrimSpace(*req.DisplayName)
+	}
+	if req.ActiveCourseID != nil {
+		p.ActiveCourseID = nil
+		if *req.ActiveCourseID != 0 {
+			course, err := h.loadCourse(*req.ActiveCourseID)
+			if err != nil {
+				respondWithContentError(w, "UpdateMe", err)
+				return
+			}
+			// Языки профиля следуют за курсом, если их не передали явно
+			p.ActiveCourseID = &course.ID
+			p.NativeLanguage, p.TargetLanguage = course.SourceLanguage, course.TargetLanguage
+		}
+	}
+	if req.NativeLanguage != nil {
+		p.NativeLanguage = strings.ToLower(strings.TrimSpace(*req.NativeLanguage))
+	}
+	if req.TargetLanguage != nil {
+		p.TargetLanguage = strings.ToLower(strings.TrimSpace(*req.TargetLanguage))
+	}
+	if req.TimeZone != nil {
+		p.TimeZone = strings.TrimSpace(*req.TimeZone)
+	}
+	if req.DailyGoal != nil {
+		p.DailyGoal = *req.DailyGoal
+	}
+	if req.PreferredTTSVoice != nil {
+		p.PreferredTTSVoice = strings.TrimSpace(*req.PreferredTTSVoice)
+	}
+	if req.UILocale != nil {
+		p.UILocale = strings.TrimSpace(*req.UILocale)
+	}
+	if n := req.Notifications; n != nil {
+		if n.DailyReminder != nil {
+			p.Notifications.DailyReminder = *n.DailyReminder
+		}
+		if n.WeeklyReport != nil {
+			p.Notifications.WeeklyReport = *n.WeeklyReport
+		}
+		if n.ProductNews != nil {
+			p.Notifications.ProductNews = *n.ProductNews
+		}
+	}
+	if err := validateProfile(p); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notifications, _ := json.Marshal(p.Notifications)
+	_, err = h.DB.Exec(`
+		INSERT INTO user_profiles (user_id, display_name, native_language, target_language, time_zone, daily_goal,
+			preferred_tts_voice, ui_locale, notification_prefs, active_course_id, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			display_name = EXCLUDED.display_name,
+			native_language = EXCLUDED.native_language,
+			target_language = EXCLUDED.target_language,
+			time_zone = EXCLUDED.time_zone,
+			daily_goal = EXCLUDED.daily_goal,
+			preferred_tts_voice = EXCLUDED.preferred_tts_voice,
+			ui_locale = EXCLUDED.ui_locale,
+			notification_prefs = EXCLUDED.notification_prefs,
+			active_course_id = EXCLUDED.active_course_id,
+			updated_at = NOW()`,
+		userID, p.DisplayName, p.NativeLanguage, p.TargetLanguage, p.TimeZone, p.DailyGoal,
+		p.PreferredTTSVoice, p.UILocale, notifications, p.ActiveCourseID)
+	if err != nil {
+		log.Printf("UpdateMe: failed to save profile for user %d: %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to save profile")
+		return
+	}
+
+	me, err := h.buildMe(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, me)
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"lingo-sprint/internal/audit"
+	"lingo-sprint/internal/models"
+)
+
+// Статусы урока. Ученикам (и гостям, и по PAT) виден только опубликованный урок,
+// у которого наступил publish_at: так уровень можно подготовить заранее и выпустить к дате.
+// Предложения видны вместе со своим уроком.
+const (
+	LessonDraft     = "draft"
+	LessonReview    = "review"
+	LessonPublished = "published"
+	LessonArchived  = "archived"
+)
+
+// publishedLessonSQL - условие видимости урока с алиасом l, для публичных запросов.
+const publishedLessonSQL = "l.status = 'published' AND (l.publish_at IS NULL OR l.publish_at <= NOW())"
+
+func IsValidLessonStatus(status string) bool {
+	switch status {
+	case LessonDraft, LessonReview, LessonPublished, LessonArchived:
+		return true
+	}
+	return false
+}
+
+type LessonStatusRequest struct {
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"` // null - опубликовать сразу (для status = published)
+}
+
+// lessonVisible проверяет урок по ID; sentenceID используется, если lessonID == 0
+// (архивное предложение не видно, даже если урок опубликован).
+func (h *ApiHandler) lessonVisible(lessonID, sentenceID int) (bool, error) {
+	var visible bool
+	var err error
+	if lessonID != 0 {
+		err = h.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM lessons l WHERE l.id = $1 AND "+publishedLessonSQL+")", lessonID).Scan(&visible)
+	} else {
+		err = h.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM sentences s JOIN lessons l ON l.id = s.lesson_id
+			WHERE s.id = $1 AND s.archived_at IS NULL AND `+publishedLessonSQL+")", sentenceID).Scan(&visible)
+	}
+	return visible, err
+}
+
+// SetLessonStatus меняет статус урока и время публикации.
+func (h *ApiHandler) SetLessonStatus(r *http.Request, lessonID int, req LessonStatusRequest) error {
+	if !IsValidLessonStatus(req.Status) {
+		return contentError(http.StatusBadRequest, "status must be one of draft, review, published, archived")
+	}
+	var oldStatus string
+	var oldPublishAt sql.NullTime
+	err := h.DB.QueryRow(`UPDATE lessons l SET status = $1, publish_at = $2 FROM lessons prev
+		WHERE l.id = $3 AND prev.id = l.id RETURNING prev.status, prev.publish_at`,
+		req.Status, req.PublishAt, lessonID).Scan(&oldStatus, &oldPublishAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return contentError(http.StatusNotFound, "Lesson not found")
+	}
+	if err != nil {
+		return err
+	}
+	var oldAt *time.Time
+	if oldPublishAt.Valid {
+		oldAt = &oldPublishAt.Time
+	}
+	if oldStatus == req.Status && samePublishAt(oldAt, req.PublishAt) {
+		return nil
+	}
+	h.recordAudit(r, audit.Event{Type: audit.ContentUpdated, TargetType: audit.TargetLesson, TargetID: contentTarget(lessonID),
+		Data: map[string]interface{}{
+			"old": map[string]interface{}{"status": oldStatus, "publish_at": oldAt},
+			"new": map[string]interface{}{"status": req.Status, "publish_at": req.PublishAt},
+		}})
+	return nil
+}
+
+func samePublishAt(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// UpdateLessonStatus (PUT /api/admin/lessons/{lesson_id}/status) публикует, снимает с публикации
+// или планирует публикацию урока.
+func (h *ApiHandler) UpdateLessonStatus(w http.ResponseWriter, r *http.Request) {
+	lessonID, ok := pathID(r, "lesson_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid lesson ID")
+		return
+	}
+	var req LessonStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.SetLessonStatus(r, lessonID, req); err != nil {
+		respondWithContentError(w, "UpdateLessonStatus", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"lesson_id": lessonID, "status": req.Status, "publish_at": req.PublishAt})
+}
+
+// ListLevelLessons (GET /api/admin/levels/{level_id}/lessons) - все уроки уровня со статусами,
+// включая черновики, которых нет в публичном GET /api/levels/{level_id}/lessons.
+func (h *ApiHandler) ListLevelLessons(w http.ResponseWriter, r *http.Request) {
+	levelID, ok := pathID(r, "level_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid level ID")
+		return
+	}
+	rows, err := h.DB.Query(`SELECT l.id, l.level_id, l.lesson_number, COALESCE(l.title, ''), l.status, l.publish_at,
+			(SELECT COUNT(*) FROM sentences s WHERE s.lesson_id = l.id AND s.archived_at IS NULL)
+		FROM lessons l WHERE l.level_id = $1 ORDER BY l.lesson_number`, levelID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+	lessons := []models.Lesson{}
+	for rows.Next() {
+		var l models.Lesson
+		if err := rows.Scan(&l.ID, &l.LevelID, &l.LessonNumber, &l.Title, &l.Status, &l.PublishAt, &l.TotalSentences); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		lessons = append(lessons, l)
+	}
+	respondWithJSON(w, http.StatusOK, lessons)
+}
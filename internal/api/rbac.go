@@ -0,0 +1,217 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"lingo-sprint/internal/audit"
+)
+
+// Роли пользователей (значения колонки users.role).
+const (
+	RoleUser          = "user"
+	RoleTeacher       = "teacher"
+	RoleContentEditor = "content_editor"
+	RoleAdmin         = "admin"
+	RoleGuest         = "guest" // анонимный пользователь до регистрации (guest.go)
+)
+
+// Права. Эндпоинты проверяют именно права, а не роли, чтобы роли можно было перекраивать.
+// Право заводится вместе с эндпоинтом, который его проверяет.
+const (
+	PermContentEdit    = "content.edit"    // создавать и править уровни/уроки/предложения
+	PermContentPublish = "content.publish" // публиковать контент
+	PermUsersManage    = "users.manage"    // роли, подписки, блокировки
+	PermAuditRead      = "audit.read"
+	PermImpersonate    = "users.impersonate"
+)
+
+// RolePermissions - какие права дает каждая роль. Роль "user" прав сверх обычного API не имеет.
+// У учителя их пока тоже нет: просмотр прогресса учеников появится отдельным эндпоинтом.
+var RolePermissions = map[string][]string{
+	RoleGuest:         {},
+	RoleUser:          {},
+	RoleTeacher:       {},
+	RoleContentEditor: {PermContentEdit, PermContentPublish},
+	RoleAdmin: {
+		PermContentEdit, PermContentPublish,
+		PermUsersManage, PermAuditRead, PermImpersonate,
+	},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+func HasPermission(role, perm string) bool {
+	for _, p := range RolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// requireMFA - роли из MFARequiredRoles пользуются своими правами только в сессии,
+// вход в которую подтвержден вторым фактором. Без 2FA такой сессии доступна лишь ее настройка
+// (/api/me/2fa/*), после чего нужно войти заново. При отказе сам пишет 403.
+func requireMFA(w http.ResponseWriter, r *http.Request, role string) bool {
+	if mfa, _ := r.Context().Value(ContextMFAKey).(bool); MFARequiredRoles[role] && !mfa {
+		respondWithError(w, http.StatusForbidden, "Two-factor authentication required")
+		return false
+	}
+	return true
+}
+
+// RequireRole пропускает только пользователей с одной из перечисленных ролей.
+// Ставится после AuthMiddleware, роль берется из JWT.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value(ContextRoleKey).(string)
+			for _, allowed := range roles {
+				if role == allowed {
+					if requireMFA(w, r, role) {
+						next.ServeHTTP(w, r)
+					}
+					return
+				}
+			}
+			respondWithError(w, http.StatusForbidden, "Insufficient role")
+		})
+	}
+}
+
+// RequirePermission пропускает только пользователей, чья роль дает право perm.
+func RequirePermission(perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value(ContextRoleKey).(string)
+			if !HasPermission(role, perm) {
+				respondWithError(w, http.StatusForbidden, "Insufficient permissions")
+				return
+			}
+			if !requireMFA(w, r, role) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// Ошибки смены роли и подписки (общие для API и админки internal/admin).
+var (
+	ErrUserNotFound        = errors.New("user not found")
+	ErrUnknownRole         = errors.New("unknown role")
+	ErrLastAdmin           = errors.New("cannot remove the last admin")
+	ErrUnknownSubscription = errors.New("unknown subscription status")
+	ErrEmailNotVerified    = errors.New("email verification required")
+)
+
+// SetUserRole меняет роль пользователя. Роль лежит в JWT, поэтому сессии пользователя
+// отзываются: с новой ролью он войдет заново.
+func (h *ApiHandler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	targetID, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req SetRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	switch err := h.ChangeUserRole(r, actorID, targetID, req.Role); {
+	case errors.Is(err, ErrUnknownRole):
+		respondWithError(w, http.StatusBadRequest, "Unknown role")
+	case errors.Is(err, ErrUserNotFound):
+		respondWithError(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, ErrLastAdmin):
+		respondWithError(w, http.StatusConflict, "Cannot remove the last admin")
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+	default:
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{"user_id": targetID, "role": req.Role})
+	}
+}
+
+// ChangeUserRole меняет роль, в той же транзакции отзывает все сессии пользователя (иначе
+// разжалованный администратор сохранил бы права до истечения токена) и пишет событие в журнал.
+// Последнего администратора разжаловать нельзя, роль "guest" назначить нельзя.
+func (h *ApiHandler) ChangeUserRole(r *http.Request, actorID, targetID int, role string) error {
+	if !IsValidRole(role) || role == RoleGuest {
+		return ErrUnknownRole
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Сначала блокируются все администраторы: иначе два админа, одновременно разжалующие
+	// друг друга, оба насчитали бы двоих и система осталась бы без администраторов
+	admins, err := lockAdmins(tx)
+	if err != nil {
+		return err
+	}
+
+	var oldRole string
+	err = tx.QueryRow("SELECT role FROM users WHERE id = $1 FOR UPDATE", targetID).Scan(&oldRole)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+	if oldRole == role {
+		return nil
+	}
+
+	// Нельзя оставить систему без администраторов
+	if oldRole == RoleAdmin && admins <= 1 {
+		return ErrLastAdmin
+	}
+
+	if _, err := tx.Exec("UPDATE users SET role = $1 WHERE id = $2", role, targetID); err != nil {
+		return err
+	}
+	if err := revokeOtherSessions(tx, targetID, ""); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.RoleChanged, ActorID: actorID, TargetType: audit.TargetUser, TargetID: userTarget(targetID),
+		Data: map[string]interface{}{"old": oldRole, "new": role}})
+	return nil
+}
+
+// lockAdmins блокирует строки администраторов до конца транзакции и возвращает их число.
+func lockAdmins(tx *sql.Tx) (int, error) {
+	rows, err := tx.Query("SELECT id FROM users WHERE role = $1 ORDER BY id FOR UPDATE", RoleAdmin)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	admins := 0
+	for rows.Next() {
+		admins++
+	}
+	return admins, rows.Err()
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// SentenceRevision - версия предложения из sentence_revisions (internal/content).
+type SentenceRevision struct {
+	Revision         int       `json:"revision"`
+	Prompt           string    `json:"prompt"`
+	Answer           string    `json:"answer"`
+	Transcription    string    `json:"transcription"`
+	ContentHash      string    `json:"content_hash"`
+	ChangedBy        *int      `json:"changed_by"` // null - импорт или система
+	Source           string    `json:"source"`
+	SourceRef        string    `json:"source_ref,omitempty"`
+	ProgressPolicy   string    `json:"progress_policy,omitempty"`
+	AffectedProgress int       `json:"affected_progress"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ListSentenceRevisions (GET /api/admin/sentences/{sentence_id}/revisions) - история правок, новые сверху.
+func (h *ApiHandler) ListSentenceRevisions(w http.ResponseWriter, r *http.Request) {
+	sentenceID, ok := pathID(r, "sentence_id")
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid sentence ID")
+		return
+	}
+	rows, err := h.DB.Query(`SELECT revision, prompt, answer, COALESCE(transcription, ''), content_hash, changed_by,
+			source, COALESCE(source_ref, ''), COALESCE(progress_policy, ''), affected_progress, created_at
+		FROM sentence_revisions WHERE sentence_id = $1 ORDER BY revision DESC`, sentenceID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+	revisions := []SentenceRevision{}
+	for rows.Next() {
+		var rev SentenceRevision
+		if err := rows.Scan(&rev.Revision, &rev.Prompt, &rev.Answer, &rev.Transcription, &rev.ContentHash, &rev.ChangedBy,
+			&rev.Source, &rev.SourceRef, &rev.ProgressPolicy, &rev.AffectedProgress, &rev.CreatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		revisions = append(revisions, rev)
+	}
+	if len(revisions) == 0 {
+		// Без версий бывает только несуществующее предложение: init.sql заводит версию 1 для всех
+		respondWithError(w, http.StatusNotFound, "Sentence not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, revisions)
+}
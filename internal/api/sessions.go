@@ -0,0 +1,57 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// startSession заводит строку в user_sessions и возвращает подписанный JWT,
+// в котором ID сессии лежит в claim "jti". AuthMiddleware проверяет, что
+// сессия не отозвана, поэтому токен можно "убить" до истечения срока.
+func (h *ApiHandler) startSession(r *http.Request, claims *Claims, ttl time.Duration) (string, error) {
+	var sessionID int
+	err := h.DB.QueryRow(`INSERT INTO user_sessions (user_id, expires_at, ip, user_agent, impersonator_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0)) RETURNING id`,
+		claims.UserID, time.Now().Add(ttl), clientIP(r), truncate(r.UserAgent(), 255), claims.ImpersonatorID).Scan(&sessionID)
+	if err != nil {
+		return "", err
+	}
+	claims.ID = strconv.Itoa(sessionID)
+	return issueToken(claims, ttl)
+}
+
+// isSessionActive - сессия существует, принадлежит пользователю и не отозвана.
+func (h *ApiHandler) isSessionActive(sessionID string, userID int) (bool, error) {
+	id, err := strconv.Atoi(sessionID)
+	if err != nil {
+		return false, nil
+	}
+	var active bool
+	err = h.DB.QueryRow("SELECT revoked_at IS NULL AND expires_at > NOW() FROM user_sessions WHERE id = $1 AND user_id = $2", id, userID).Scan(&active)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return active, err
+}
+
+// revokeOtherSessions отзывает все сессии пользователя, кроме текущей (keepSessionID может быть пустым).
+func revokeOtherSessions(q execer, userID int, keepSessionID string) error {
+	keep, _ := strconv.Atoi(keepSessionID)
+	_, err := q.Exec("UPDATE user_sessions SET revoked_at = NOW() WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL", userID, keep)
+	return err
+}
+
+// execer - общее у *sql.DB и *sql.Tx, чтобы хелперы работали и внутри транзакции.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
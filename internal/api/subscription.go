@@ -0,0 +1,58 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"lingo-sprint/internal/audit"
+)
+
+// Значения users.subscription_status.
+const (
+	SubscriptionFree    = "free"
+	SubscriptionPremium = "premium"
+)
+
+func IsValidSubscription(status string) bool {
+	return status == SubscriptionFree || status == SubscriptionPremium
+}
+
+// ChangeSubscription вручную меняет подписку пользователя (например, из админки) и пишет событие в журнал.
+// Переход на premium подчиняется политике UnverifiedRestrictions (ActionPremiumPurchase).
+func (h *ApiHandler) ChangeSubscription(r *http.Request, actorID, targetID int, status string) error {
+	if !IsValidSubscription(status) {
+		return ErrUnknownSubscription
+	}
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var old string
+	var verified bool
+	err = tx.QueryRow("SELECT subscription_status, email_verified_at IS NOT NULL FROM users WHERE id = $1 FOR UPDATE", targetID).
+		Scan(&old, &verified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if old == status {
+		return nil
+	}
+	if status == SubscriptionPremium && !verified && UnverifiedRestrictions[ActionPremiumPurchase] {
+		return ErrEmailNotVerified
+	}
+	if _, err := tx.Exec("UPDATE users SET subscription_status = $1 WHERE id = $2", status, targetID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.recordAudit(r, audit.Event{Type: audit.SubscriptionChanged, ActorID: actorID, TargetType: audit.TargetUser, TargetID: userTarget(targetID),
+		Data: map[string]interface{}{"old": old, "new": status}})
+	return nil
+}
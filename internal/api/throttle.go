@@ -0,0 +1,264 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Действия, для которых считаем попытки (в дополнение к ActionLogin).
+const (
+	ActionRegister    = "register"
+	ActionResendEmail = "resend_email"
+)
+
+// ThrottlePolicy описывает, когда и насколько блокировать ключ (email или IP).
+type ThrottlePolicy struct {
+	MaxFailures   int           // неудач с одного email до блокировки
+	MaxFailuresIP int           // неудач с одного IP до блокировки (за NAT сидит много людей)
+	BaseLockout   time.Duration // первая блокировка, каждая следующая неудача удваивает срок
+	MaxLockout    time.Duration
+	Window        time.Duration // неудачи старше окна забываются
+	CountAll      bool          // считать каждый запрос, а не только неудачные (для регистрации)
+}
+
+// ThrottlePolicies - настройки по умолчанию. Порог для входа можно поменять через LOGIN_MAX_FAILURES.
+var ThrottlePolicies = map[string]ThrottlePolicy{
+	ActionLogin: {
+		MaxFailures:   envInt("LOGIN_MAX_FAILURES", 5),
+		MaxFailuresIP: envInt("LOGIN_MAX_FAILURES_IP", 50),
+		BaseLockout:   time.Minute,
+		MaxLockout:    24 * time.Hour,
+		Window:        time.Hour,
+	},
+	ActionLogin2FA: {
+		MaxFailures:   5, // ключ "user:<id>": email в теле второго шага нет
+		MaxFailuresIP: 50,
+		BaseLockout:   5 * time.Minute,
+		MaxLockout:    24 * time.Hour,
+		Window:        time.Hour,
+	},
+	ActionChangePassword: {
+		MaxFailures: 5, // ключ "user:<id>": перебор пароля из украденной сессии
+		BaseLockout: 5 * time.Minute,
+		MaxLockout:  24 * time.Hour,
+		Window:      time.Hour,
+	},
+	ActionResendEmail: {
+		MaxFailures:   5,
+		MaxFailuresIP: 20,
+		BaseLockout:   time.Hour,
+		MaxLockout:    24 * time.Hour,
+		Window:        24 * time.Hour,
+		CountAll:      true,
+	},
+	ActionGuest: {
+		MaxFailuresIP: 20, // каждый гость - строка в users
+		BaseLockout:   time.Hour,
+		MaxLockout:    24 * time.Hour,
+		Window:        24 * time.Hour,
+		CountAll:      true,
+	},
+	ActionRegister: {
+		MaxFailuresIP: 10,
+		BaseLockout:   time.Hour,
+		MaxLockout:    24 * time.Hour,
+		Window:        24 * time.Hour,
+		CountAll:      true,
+	},
+}
+
+// TrustProxyHeaders - брать IP клиента из X-Forwarded-For (только за своим reverse proxy!).
+var TrustProxyHeaders = envOrDefault("TRUST_PROXY_HEADERS", "") == "true"
+
+// TrustedProxyHops - сколько своих прокси стоит перед сервером (TRUSTED_PROXY_HOPS). Каждый
+// дописывает адрес в конец X-Forwarded-For, поэтому адрес клиента - hops-й с конца;
+// все, что левее, прислал сам клиент и может подделать.
+var TrustedProxyHops = envInt("TRUSTED_PROXY_HOPS", 1)
+
+// AttemptStore хранит счетчики неудачных попыток в таблице auth_throttle.
+type AttemptStore struct {
+	DB *sql.DB
+}
+
+// Check возвращает, сколько еще ждать, если хотя бы один из ключей заблокирован.
+func (s *AttemptStore) Check(action string, keys ...string) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range keys {
+		var lockedUntil time.Time
+		err := s.DB.QueryRow("SELECT locked_until FROM auth_throttle WHERE action = $1 AND key = $2 AND locked_until > NOW()", action, key).Scan(&lockedUntil)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if d := time.Until(lockedUntil); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// Fail засчитывает неудачу по ключу и при превышении порога ставит блокировку.
+// Возвращает срок блокировки (0, если ключ не заблокирован).
+func (s *AttemptStore) Fail(action, key string, limit int, ip string) (time.Duration, error) {
+	policy := ThrottlePolicies[action]
+	var failures int
+	err := s.DB.QueryRow(`
+		INSERT INTO auth_throttle (action, key, failures, last_failure_at) VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (action, key) DO UPDATE SET
+			failures = CASE WHEN auth_throttle.last_failure_at < NOW() - make_interval(secs => $3) THEN 1 ELSE auth_throttle.failures + 1 END,
+			last_failure_at = NOW()
+		RETURNING failures`, action, key, policy.Window.Seconds()).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+	if limit <= 0 || failures < limit {
+		return 0, nil
+	}
+
+	// Экспоненциальный рост: Base, 2*Base, 4*Base ... но не больше MaxLockout
+	lockout := time.Duration(float64(policy.BaseLockout) * math.Pow(2, float64(failures-limit)))
+	if lockout <= 0 || lockout > policy.MaxLockout {
+		lockout = policy.MaxLockout
+	}
+	lockedUntil := time.Now().Add(lockout)
+	if _, err := s.DB.Exec("UPDATE auth_throttle SET locked_until = $3 WHERE action = $1 AND key = $2", action, key, lockedUntil); err != nil {
+		return 0, err
+	}
+	_, err = s.DB.Exec("INSERT INTO auth_lockouts (action, key, failures, locked_until, ip) VALUES ($1, $2, $3, $4, $5)",
+		action, key, failures, lockedUntil, ip)
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("Throttle: %s locked for %s on %q after %d failures (ip %s)", action, lockout, key, failures, ip)
+	return lockout, nil
+}
+
+// Reset сбрасывает счетчик (например, после успешного входа).
+func (s *AttemptStore) Reset(action, key string) error {
+	_, err := s.DB.Exec("DELETE FROM auth_throttle WHERE action = $1 AND key = $2", action, key)
+	return err
+}
+
+// Throttle - middleware для публичных auth-эндпоинтов. Отвечает 429 с Retry-After,
+// если email из тела запроса или IP клиента заблокированы, и засчитывает неудачи по коду ответа.
+func (h *ApiHandler) Throttle(action string) func(http.Handler) http.Handler {
+	policy := ThrottlePolicies[action]
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r)
+			ipKey := "ip:" + ip
+			keys := []string{ipKey}
+			emailKey := ""
+			if email := peekEmail(r); email != "" {
+				emailKey = "email:" + email
+				keys = append(keys, emailKey)
+			}
+
+			wait, err := h.Attempts.Check(action, keys...)
+			if err != nil {
+				log.Printf("Throttle: check failed: %v", err)
+				respondWithError(w, http.StatusInternalServerError, "Database error")
+				return
+			}
+			if wait > 0 {
+				respondTooManyAttempts(w, wait)
+				return
+			}
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			failed := policy.CountAll || rec.status == http.StatusUnauthorized || rec.status == http.StatusBadRequest
+			if rec.status >= 500 {
+				return
+			}
+			if !failed {
+				if emailKey != "" {
+					if err := h.Attempts.Reset(action, emailKey); err != nil {
+						log.Printf("Throttle: reset failed: %v", err)
+					}
+				}
+				return
+			}
+			if _, err := h.Attempts.Fail(action, ipKey, policy.MaxFailuresIP, ip); err != nil {
+				log.Printf("Throttle: failed to record attempt: %v", err)
+			}
+			if emailKey != "" && policy.MaxFailures > 0 {
+				if _, err := h.Attempts.Fail(action, emailKey, policy.MaxFailures, ip); err != nil {
+					log.Printf("Throttle: failed to record attempt: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func respondTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, "Too many attempts, try again later")
+}
+
+// statusRecorder запоминает код ответа, чтобы middleware знало, чем закончился запрос.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// peekEmail достает поле "email" из JSON-тела и возвращает тело на место для обработчика.
+func peekEmail(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return ""
+	}
+	return normalizeEmail(payload.Email)
+}
+
+func clientIP(r *http.Request) string {
+	if TrustProxyHeaders && TrustedProxyHops > 0 {
+		// Несколько заголовков X-Forwarded-For склеиваются по порядку, как одна цепочка
+		var hops []string
+		for _, fwd := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(fwd, ",")...)
+		}
+		if len(hops) > 0 {
+			i := len(hops) - TrustedProxyHops
+			if i < 0 {
+				i = 0 // цепочка короче ожидаемой: все адреса в ней дописаны нашими прокси
+			}
+			if ip := strings.TrimSpace(hops[i]); net.ParseIP(ip) != nil {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
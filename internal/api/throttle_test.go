@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	defer func(trust bool, hops int) { TrustProxyHeaders, TrustedProxyHops = trust, hops }(TrustProxyHeaders, TrustedProxyHops)
+
+	tests := []struct {
+		name  string
+		trust bool
+		hops  int
+		xff   []string
+		want  string
+	}{
+		{"заголовкам не доверяем", false, 1, []string{"203.0.113.7"}, "192.0.2.1"},
+		{"один прокси", true, 1, []string{"203.0.113.7"}, "203.0.113.7"},
+		// Клиент сам прислал X-Forwarded-For: левый адрес подделан, верить можно только дописанному прокси
+		{"подделанный левый адрес", true, 1, []string{"10.0.0.1, 203.0.113.7"}, "203.0.113.7"},
+		{"два прокси", true, 2, []string{"10.0.0.1, 203.0.113.7, 198.51.100.2"}, "203.0.113.7"},
+		{"несколько заголовков - одна цепочка", true, 2, []string{"10.0.0.1", "203.0.113.7, 198.51.100.2"}, "203.0.113.7"},
+		{"цепочка короче числа прокси", true, 3, []string{"203.0.113.7, 198.51.100.2"}, "203.0.113.7"},
+		{"IPv6", true, 1, []string{"2001:db8::1"}, "2001:db8::1"},
+		{"не IP - адрес соединения", true, 1, []string{"unknown"}, "192.0.2.1"},
+		{"без заголовка", true, 1, nil, "192.0.2.1"},
+		{"hops = 0 - заголовок не читается", true, 0, []string{"203.0.113.7"}, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		TrustProxyHeaders, TrustedProxyHops = tt.trust, tt.hops
+		r := httptest.NewRequest("GET", "/api/login", nil)
+		r.RemoteAddr = "192.0.2.1:54321"
+		for _, v := range tt.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := clientIP(r); got != tt.want {
+			t.Errorf("%s: clientIP = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+
+	"lingo-sprint/internal/audit"
+)
+
+// Персональные токены доступа (PAT) - для скриптов и интеграций вместо пароля.
+// Токен показывается один раз при создании, в БД хранится только его SHA-256.
+const (
+	patPrefix         = "lsp_" // по префиксу TokenAuth отличает PAT от JWT
+	patDefaultTTLDays = 90
+	patMaxTTLDays     = 365
+	patMaxPerUser     = 20
+	patMaxNameLen     = 100
+)
+
+// Scope персонального токена: что можно делать по нему.
+const (
+	ScopeReadProgress  = "read-progress"
+	ScopeWriteProgress = "write-progress"
+	ScopeReadContent   = "read-content"
+)
+
+var tokenScopes = map[string]bool{ScopeReadProgress: true, ScopeWriteProgress: true, ScopeReadContent: true}
+
+// ContextTokenScopesKey - scope персонального токена, если запрос пришел по PAT.
+const ContextTokenScopesKey UserIDKey = "tokenScopes"
+
+// tokenAllows - разрешен ли запросу scope: сессии (JWT) можно все, PAT - только выданное.
+func tokenAllows(r *http.Request, scope string) bool {
+	granted, ok := r.Context().Value(ContextTokenScopesKey).(map[string]bool)
+	return !ok || granted[scope]
+}
+
+type PersonalAccessToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // первые символы токена, чтобы узнать его в списке
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// TokenAuth - как AuthMiddleware, но дополнительно пускает по персональному токену,
+// если у него есть все перечисленные scope. Маршруты без TokenAuth по PAT недоступны.
+func (h *ApiHandler) TokenAuth(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString, ok := bearerToken(w, r)
+			if !ok {
+				return
+			}
+			if strings.HasPrefix(tokenString, patPrefix) {
+				ctx, ok := h.authenticatePAT(w, r, tokenString, scopes)
+				if ok {
+					next.ServeHTTP(w, r.WithContext(ctx))
+				}
+				return
+			}
+			ctx, claims, ok := h.authenticateJWT(w, r, tokenString)
+			if ok {
+				h.serveAuthenticated(w, r.WithContext(ctx), claims, next)
+			}
+		})
+	}
+}
+
+func (h *ApiHandler) authenticatePAT(w http.ResponseWriter, r *http.Request, tokenString string, required []string) (context.Context, bool) {
+	var tokenID, userID int
+	var role, scopes string
+	err := h.DB.QueryRow(`SELECT t.id, t.user_id, u.role, t.scopes
+		FROM personal_access_tokens t JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1 AND t.revoked_at IS NULL AND t.expires_at > NOW() AND u.deleted_at IS NULL`,
+		hashToken(tokenString)).Scan(&tokenID, &userID, &role, &scopes)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return nil, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+
+	granted := parseSet(scopes)
+	for _, scope := range required {
+		if !granted[scope] {
+			respondWithError(w, http.StatusForbidden, "Token lacks scope "+scope)
+			return nil, false
+		}
+	}
+
+	// Не пишем в БД на каждый запрос: отметки раз в минуту достаточно
+	if _, err := h.DB.Exec("UPDATE personal_access_tokens SET last_used_at = NOW() WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')", tokenID); err != nil {
+		log.Printf("authenticatePAT: failed to update last_used_at for token %d: %v", tokenID, err)
+	}
+
+	ctx := context.WithValue(r.Context(), ContextUserIDKey, userID)
+	ctx = context.WithValue(ctx, ContextRoleKey, role)
+	ctx = context.WithValue(ctx, ContextTokenScopesKey, granted)
+	return ctx, true
+}
+
+type CreateTokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"` // 0 = patDefaultTTLDays
+}
+
+// CreateToken (POST /api/me/tokens) выпускает персональный токен. Сам токен есть только в этом ответе.
+func (h *ApiHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	var req CreateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > patMaxNameLen {
+		respondWithError(w, http.StatusBadRequest, "name must be 1-100 characters")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !tokenScopes[scope] {
+			respondWithError(w, http.StatusBadRequest, "Unknown scope "+scope)
+			return
+		}
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = patDefaultTTLDays
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > patMaxTTLDays {
+		respondWithError(w, http.StatusBadRequest, "expires_in_days must be between 1 and 365")
+		return
+	}
+
+	var active int
+	if err := h.DB.QueryRow("SELECT COUNT(*) FROM personal_access_tokens WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()", userID).Scan(&active); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if active >= patMaxPerUser {
+		respondWithError(w, http.StatusConflict, "Too many active tokens, revoke unused ones first")
+		return
+	}
+
+	secret, _, err := randomToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	token := patPrefix + secret
+	pat := PersonalAccessToken{
+		Name:      req.Name,
+		Prefix:    token[:len(patPrefix)+6],
+		Scopes:    normalizeScopes(req.Scopes),
+		ExpiresAt: time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour),
+	}
+	err = h.DB.QueryRow(`INSERT INTO personal_access_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		userID, pat.Name, hashToken(token), pat.Prefix, strings.Join(pat.Scopes, ","), pat.ExpiresAt).Scan(&pat.ID, &pat.CreatedAt)
+	if err != nil {
+		log.Printf("CreateToken: user %d: %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	h.recordAudit(r, audit.Event{Type: audit.TokenCreated, TargetType: audit.TargetToken, TargetID: strconv.Itoa(pat.ID),
+		Data: map[string]interface{}{"name": pat.Name, "scopes": pat.Scopes, "expires_at": pat.ExpiresAt}})
+	respondWithJSON(w, http.StatusCreated, struct {
+		PersonalAccessToken
+		Token string `json:"token"`
+	}{pat, token})
+}
+
+// normalizeScopes убирает дубли и сортирует scope в фиксированном порядке.
+func normalizeScopes(scopes []string) []string {
+	set := map[string]bool{}
+	for _, s := range scopes {
+		set[s] = true
+	}
+	out := []string{}
+	for _, s := range []string{ScopeReadContent, ScopeReadProgress, ScopeWriteProgress} {
+		if set[s] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ListTokens (GET /api/me/tokens) - действующие токены пользователя (без самих секретов).
+func (h *ApiHandler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	rows, err := h.DB.Query(`SELECT id, name, token_prefix, scopes, created_at, expires_at, last_used_at
+		FROM personal_access_tokens WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC`, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+	tokens := []PersonalAccessToken{}
+	for rows.Next() {
+		var t PersonalAccessToken
+		var scopes string
+		if err := rows.Scan(&t.ID, &t.Name, &t.Prefix, &scopes, &t.CreatedAt, &t.ExpiresAt, &t.LastUsedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		t.Scopes = strings.Split(scopes, ",")
+		tokens = append(tokens, t)
+	}
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+// RevokeToken (DELETE /api/me/tokens/{token_id}) отзывает токен; отозванный токен сразу перестает работать.
+func (h *ApiHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	tokenID, err := strconv.Atoi(mux.Vars(r)["token_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+	res, err := h.DB.Exec("UPDATE personal_access_tokens SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL", tokenID, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "Token not found")
+		return
+	}
+	h.recordAudit(r, audit.Event{Type: audit.TokenRevoked, TargetType: audit.TargetToken, TargetID: strconv.Itoa(tokenID)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAllTokens отзывает все персональные токены пользователя (например, когда аккаунт
+// переходит к настоящему владельцу email, см. resolveIdentity).
+func revokeAllTokens(q execer, userID int) error {
+	_, err := q.Exec("UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	return err
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"lingo-sprint/internal/audit"
+)
+
+// TOTP по RFC 6238: HMAC-SHA1, шаг 30 секунд, 6 цифр. Это то, что понимают
+// Google Authenticator, Яндекс Ключ, 1Password и т.п.
+const (
+	totpIssuer        = "Lingo Sprint"
+	totpPeriod        = 30
+	totpDigits        = 6
+	totpSkew          = 1 // принимаем коды соседних шагов (рассинхрон часов)
+	recoveryCodeCount = 10
+
+	PurposeMFAChallenge = "mfa"
+	mfaChallengeTTL     = 5 * time.Minute
+
+	ActionLogin2FA = "login_2fa"
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpCode считает код для конкретного шага времени.
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// matchTOTP ищет шаг, которому соответствует код. Возвращает -1, если код не подошел.
+func matchTOTP(secretB32, code string, now time.Time) int64 {
+	secret, err := b32.DecodeString(strings.ToUpper(secretB32))
+	if err != nil || len(code) != totpDigits {
+		return -1
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if hmac.Equal([]byte(totpCode(secret, step)), []byte(code)) {
+			return step
+		}
+	}
+	return -1
+}
+
+func provisioningURI(secretB32, email string) string {
+	label := url.PathEscape(totpIssuer + ":" + email)
+	q := url.Values{}
+	q.Set("secret", secretB32)
+	q.Set("issuer", totpIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", strconv.Itoa(totpDigits))
+	q.Set("period", strconv.Itoa(totpPeriod))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// newRecoveryCodes генерирует одноразовые коды вида "abcde-fghij".
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		c := strings.ToLower(b32.EncodeToString(b))[:10]
+		codes[i] = c[:5] + "-" + c[5:]
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// verifySecondFactor принимает либо код TOTP, либо неиспользованный код восстановления.
+// Один и тот же код TOTP дважды не принимается.
+func (h *ApiHandler) verifySecondFactor(userID int, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	var secret sql.NullString
+	if err := h.DB.QueryRow("SELECT totp_secret FROM users WHERE id = $1", userID).Scan(&secret); err != nil {
+		return false, err
+	}
+	if !secret.Valid {
+		return false, nil
+	}
+
+	if step := matchTOTP(secret.String, code, time.Now()); step >= 0 {
+		res, err := h.DB.Exec("UPDATE users SET totp_last_step = $1 WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1)", step, userID)
+		if err != nil {
+			return false, err
+		}
+		n, _ := res.RowsAffected()
+		return n == 1, nil
+	}
+
+	res, err := h.DB.Exec("UPDATE totp_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL",
+		userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	if n == 1 {
+		log.Printf("2FA: user %d used a recovery code", userID)
+	}
+	return n == 1, nil
+}
+
+// EnrollTOTP создает новый секрет (еще не активный) и возвращает URI для QR-кода.
+func (h *ApiHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate secret")
+		return
+	}
+	secret := b32.EncodeToString(raw)
+
+	var email string
+	err := h.DB.QueryRow("UPDATE users SET totp_secret = $1, totp_last_step = NULL WHERE id = $2 AND totp_enabled_at IS NULL RETURNING email", secret, userID).Scan(&email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+		}
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"secret":           secret,
+		"provisioning_uri": provisioningURI(secret, email),
+	})
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// ConfirmTOTP включает 2FA после ввода первого кода и один раз показывает коды восстановления.
+func (h *ApiHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	var req TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	var secret sql.NullString
+	var enabled bool
+	if err := h.DB.QueryRow("SELECT totp_secret, totp_enabled_at IS NOT NULL FROM users WHERE id = $1", userID).Scan(&secret, &enabled); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if enabled {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if !secret.Valid {
+		respondWithError(w, http.StatusBadRequest, "Start enrollment first")
+		return
+	}
+	step := matchTOTP(secret.String, strings.TrimSpace(req.Code), time.Now())
+	if step < 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+	tx, err := h.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $1 WHERE id = $2", step, userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM totp_recovery_codes WHERE user_id = $1", userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	for _, c := range codes {
+		if _, err := tx.Exec("INSERT INTO totp_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hashToken(normalizeRecoveryCode(c))); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	h.recordAudit(r, audit.Event{Type: audit.TwoFactorEnabled, TargetType: audit.TargetUser, TargetID: userTarget(userID)})
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// DisableTOTP выключает 2FA. Нужны и пароль, и действующий код (или код восстановления);
+// у аккаунта без пароля вместо пароля - недавний вход (reauthenticate).
+func (h *ApiHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(ContextUserIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	var req DisableTOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	// Пароль - с тем же лимитом попыток, что при смене пароля: иначе украденная сессия подбирала бы его здесь
+	if !h.reauthenticate(w, r, userID, req.Password, "") {
+		return
+	}
+	valid, err := h.verifySecondFactor(userID, req.Code)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !valid {
+		respondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	if _, err := h.DB.Exec("UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = $1", userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if _, err := h.DB.Exec("DELETE FROM totp_recovery_codes WHERE user_id = $1", userID); err != nil {
+		log.Printf("DisableTOTP: failed to delete recovery codes for user %d: %v", userID, err)
+	}
+	h.recordAudit(r, audit.Event{Type: audit.TwoFactorDisabled, TargetType: audit.TargetUser, TargetID: userTarget(userID)})
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+type LoginTOTPRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+// LoginTOTP - второй шаг входа: challenge-токен из LoginUser + код.
+func (h *ApiHandler) LoginTOTP(w http.ResponseWriter, r *http.Request) {
+	var req LoginTOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(req.ChallengeToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return JwtKey, nil
+	})
+	if err != nil || !token.Valid || claims.Purpose != PurposeMFAChallenge {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge")
+		return
+	}
+	userID := claims.UserID
+
+	userKey := "user:" + strconv.Itoa(userID)
+	wait, err := h.Attempts.Check(ActionLogin2FA, userKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+
+	valid, err := h.verifySecondFactor(userID, req.Code)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !valid {
+		if _, err := h.Attempts.Fail(ActionLogin2FA, userKey, ThrottlePolicies[ActionLogin2FA].MaxFailures, clientIP(r)); err != nil {
+			log.Printf("LoginTOTP: failed to record attempt: %v", err)
+		}
+		h.recordAudit(r, audit.Event{Type: audit.LoginFailed, TargetType: audit.TargetUser, TargetID: userTarget(userID), Data: map[string]interface{}{"reason": "bad_2fa_code"}})
+		respondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+	if err := h.Attempts.Reset(ActionLogin2FA, userKey); err != nil {
+		log.Printf("LoginTOTP: failed to reset attempts: %v", err)
+	}
+
+	var role string
+	if err := h.DB.QueryRow("SELECT role FROM users WHERE id = $1", userID).Scan(&role); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	h.respondWithSession(w, r, userID, role, true)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+// Секрет и коды - тестовые векторы RFC 6238 (SHA1), обрезанные до 6 цифр.
+var rfcSecret = b32.EncodeToString([]byte("12345678901234567890"))
+
+func TestTotpCode(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(secret, tt.unix/totpPeriod); got != tt.want {
+			t.Errorf("totpCode(t=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	at := func(unix int64) time.Time { return time.Unix(unix, 0) }
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		now    time.Time
+		want   int64
+	}{
+		{"текущий шаг", rfcSecret, "287082", at(59), 1},
+		{"предыдущий шаг (часы клиента отстают)", rfcSecret, "287082", at(89), 1},
+		{"следующий шаг (часы клиента спешат)", rfcSecret, "287082", at(29), 1},
+		{"два шага назад - уже нет", rfcSecret, "287082", at(119), -1},
+		{"секрет в нижнем регистре", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "287082", at(59), 1},
+		{"неверный код", rfcSecret, "287083", at(59), -1},
+		{"короткий код", rfcSecret, "28708", at(59), -1},
+		{"испорченный секрет", "not base32!", "287082", at(59), -1},
+	}
+	for _, tt := range tests {
+		if got := matchTOTP(tt.secret, tt.code, tt.now); got != tt.want {
+			t.Errorf("%s: matchTOTP = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ContentVersion - версия контента (таблица content_version). Растет при каждой загрузке
+// scripts/data_loader, каждой правке уровней, уроков и предложений и когда наступает
+// publish_at запланированного урока, так что клиенту достаточно сравнить число, чтобы понять,
+// пора ли перечитать уроки.
+type ContentVersion struct {
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// releaseDueLessonsSQL читает версию, а если с прошлого раза наступил publish_at какого-то
+// опубликованного урока, сначала увеличивает ее на 1: строки при этом не меняются и триггеры
+// bump_content_version не срабатывают. Запись бывает только в момент выпуска; при одновременных
+// запросах второй UPDATE видит новый released_until и версию не увеличивает.
+const releaseDueLessonsSQL = `WITH released AS (
+		UPDATE content_version SET version = version + 1, updated_at = NOW(), released_until = NOW()
+		WHERE id = 1 AND EXISTS (SELECT 1 FROM lessons
+			WHERE status = 'published' AND publish_at > content_version.released_until AND publish_at <= NOW())
+		RETURNING version, updated_at
+	)
+	SELECT version, updated_at FROM released
+	UNION ALL
+	SELECT version, updated_at FROM content_version WHERE id = 1 AND NOT EXISTS (SELECT 1 FROM released)`
+
+// GetContentVersion отдает текущую версию контента. Эндпоинт публичный и дешевый:
+// клиенты опрашивают его с If-None-Match и получают 304, пока версия та же.
+func (h *ApiHandler) GetContentVersion(w http.ResponseWriter, r *http.Request) {
+	var v ContentVersion
+	err := h.DB.QueryRow(releaseDueLessonsSQL).Scan(&v.Version, &v.UpdatedAt)
+	if err != nil {
+		log.Printf("content version: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to get content version")
+		return
+	}
+	etag := `"` + strconv.FormatInt(v.Version, 10) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, v)
+}
@@ -0,0 +1,180 @@
+// Package audit - журнал событий безопасности и администрирования (таблица audit_events).
+// Журнал только дополняется: изменить или удалить запись не дает триггер в БД.
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Типы событий. Префикс до точки - область, по нему удобно фильтровать ("auth.*").
+const (
+	LoginSucceeded       = "auth.login"
+	LoginFailed          = "auth.login_failed"
+	Registered           = "auth.registered"
+	EmailVerified        = "auth.email_verified"
+	PasswordChanged      = "auth.password_changed"
+	EmailChangeRequested = "auth.email_change_requested"
+	EmailChanged         = "auth.email_changed"
+	TwoFactorEnabled     = "auth.2fa_enabled"
+	TwoFactorDisabled    = "auth.2fa_disabled"
+	IdentityLinked       = "auth.identity_linked"
+	TokenCreated         = "auth.token_created"
+	TokenRevoked         = "auth.token_revoked"
+	AccountDeleted       = "user.deleted"
+	AccountRestored      = "user.restored"
+	AccountPurged        = "user.purged"
+	RoleChanged          = "user.role_changed"
+	SubscriptionChanged  = "user.subscription_changed"
+	ImpersonationStarted = "impersonation.started"
+	ImpersonationRequest = "impersonation.request"
+	ContentCreated       = "content.created"
+	ContentUpdated       = "content.updated"
+	ContentDeleted       = "content.deleted"
+)
+
+// Типы целей события (Event.TargetType).
+const (
+	TargetUser     = "user"
+	TargetToken    = "token"
+	TargetCourse   = "course"
+	TargetLevel    = "level"
+	TargetLesson   = "lesson"
+	TargetSentence = "sentence"
+)
+
+// Event - одно событие. ActorID = 0 - аноним или система (например, фоновая очистка).
+type Event struct {
+	Type           string                 `json:"event_type"`
+	ActorID        int                    `json:"actor_id,omitempty"`
+	ImpersonatorID int                    `json:"impersonator_id,omitempty"` // администратор, действовавший от имени ActorID
+	TargetType     string                 `json:"target_type,omitempty"`
+	TargetID       string                 `json:"target_id,omitempty"`
+	IP             string                 `json:"ip,omitempty"`
+	UserAgent      string                 `json:"user_agent,omitempty"`
+	Data           map[string]interface{} `json:"data,omitempty"` // подробности: старое/новое значение, причина и т.п.
+}
+
+// Entry - сохраненное событие.
+type Entry struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Event
+}
+
+// Log пишет и читает audit_events.
+type Log struct {
+	DB *sql.DB
+}
+
+func New(db *sql.DB) *Log {
+	return &Log{DB: db}
+}
+
+// Record добавляет событие в журнал.
+func (l *Log) Record(e Event) error {
+	if e.Type == "" {
+		return fmt.Errorf("audit: event type is required")
+	}
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	_, err = l.DB.Exec(`INSERT INTO audit_events (event_type, actor_id, impersonator_id, target_type, target_id, ip, user_agent, data)
+		VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8)`,
+		e.Type, e.ActorID, e.ImpersonatorID, e.TargetType, e.TargetID, e.IP, e.UserAgent, data)
+	return err
+}
+
+// Filter - условия выборки. Пустые поля не фильтруют.
+type Filter struct {
+	ActorID    int
+	TargetType string
+	TargetID   string
+	Type       string // точный тип или префикс с "*": "auth.*"
+	From, To   time.Time
+	BeforeID   int64 // для постраничного просмотра: записи старше этой
+	Limit      int
+}
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Query возвращает события от новых к старым.
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	var where []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.ActorID != 0 {
+		add("actor_id = $%d", f.ActorID)
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $%d", f.TargetID)
+	}
+	if prefix, ok := strings.CutSuffix(f.Type, "*"); ok {
+		add("event_type LIKE $%d", escapeLike(prefix)+"%")
+	} else if f.Type != "" {
+		add("event_type = $%d", f.Type)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+	if f.BeforeID != 0 {
+		add("id < $%d", f.BeforeID)
+	}
+	if f.Limit <= 0 {
+		f.Limit = DefaultLimit
+	}
+	if f.Limit > MaxLimit {
+		f.Limit = MaxLimit
+	}
+
+	query := `SELECT id, created_at, event_type, COALESCE(actor_id, 0), COALESCE(impersonator_id, 0),
+		COALESCE(target_type, ''), COALESCE(target_id, ''), COALESCE(ip, ''), COALESCE(user_agent, ''), data
+		FROM audit_events`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := l.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &e.Type, &e.ActorID, &e.ImpersonatorID,
+			&e.TargetType, &e.TargetID, &e.IP, &e.UserAgent, &data); err != nil {
+			return nil, err
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &e.Data); err != nil {
+				return nil, fmt.Errorf("audit: event %d: %w", e.ID, err)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,154 @@
+// Package content - общее для всех, кто меняет предложения (API, админ-панель, scripts/data_loader):
+// хеш содержимого, история правок (sentence_revisions) и политика для прогресса учеников,
+// когда правильный ответ меняется по существу.
+package content
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Источник правки (sentence_revisions.source).
+const (
+	SourceEditor     = "editor"      // REST API или админ-панель, changed_by - редактор
+	SourceDataLoader = "data_loader" // импорт CSV, changed_by пустой
+	SourceBaseline   = "baseline"    // версия, с которой началась история (заполняет init.sql)
+)
+
+// Что делать с прогрессом учеников, если ответ изменился по существу.
+const (
+	PolicyKeep      = "keep"      // оставить как есть
+	PolicyDowngrade = "downgrade" // mastered -> learning, предложение вернется в повторение
+	PolicyReset     = "reset"     // удалить прогресс по предложению (история попыток остается)
+
+	DefaultPolicy = PolicyDowngrade
+)
+
+func IsValidPolicy(policy string) bool {
+	switch policy {
+	case PolicyKeep, PolicyDowngrade, PolicyReset:
+		return true
+	}
+	return false
+}
+
+// Hash - хеш содержимого предложения. Тот же хеш считает init.sql при заполнении
+// sentences.content_hash, поэтому формат менять только вместе с ним.
+func Hash(prompt, answer, transcription string) string {
+	sum := sha256.Sum256([]byte(prompt + "\x1f" + answer + "\x1f" + transcription))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeAnswer убирает то, что не меняет смысл ответа: регистр, лишние пробелы,
+// знаки препинания и вид кавычек/апострофов.
+func normalizeAnswer(s string) string {
+	s = strings.NewReplacer("’", "'", "‘", "'", "“", `"`, "”", `"`).Replace(strings.ToLower(s))
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+		case unicode.IsPunct(r) && r != '\'':
+			// пунктуация не считается, но разделяет слова ("well,done" = "well, done")
+			space = true
+		default:
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// MaterialChange - ответ изменился по существу (а не только пунктуацией, регистром или пробелами).
+func MaterialChange(oldAnswer, newAnswer string) bool {
+	return normalizeAnswer(oldAnswer) != normalizeAnswer(newAnswer)
+}
+
+// Revision - одна версия предложения.
+type Revision struct {
+	SentenceID    int
+	Prompt        string
+	Answer        string
+	Transcription string
+	ChangedBy     int    // ID пользователя; 0 - импорт или система
+	Source        string // SourceEditor, SourceDataLoader
+	SourceRef     string // откуда правка: путь к CSV и т.п.
+	Policy        string // примененная политика прогресса; пусто - ответ по существу не менялся
+	Affected      int64  // сколько записей user_progress затронула политика
+}
+
+// RecordRevision сохраняет новую версию предложения и обновляет sentences.content_hash.
+// Вызывать в той же транзакции, что и саму правку, после нее.
+func RecordRevision(tx *sql.Tx, rev Revision) (int, error) {
+	hash := Hash(rev.Prompt, rev.Answer, rev.Transcription)
+	if _, err := tx.Exec("UPDATE sentences SET content_hash = $1 WHERE id = $2", hash, rev.SentenceID); err != nil {
+		return 0, err
+	}
+	var number int
+	err := tx.QueryRow(`INSERT INTO sentence_revisions (sentence_id, revision, prompt, answer, transcription, content_hash,
+			changed_by, source, source_ref, progress_policy, affected_progress)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, 0), $7, NULLIF($8, ''), NULLIF($9, ''), $10
+		FROM sentence_revisions WHERE sentence_id = $1
+		RETURNING revision`,
+		rev.SentenceID, rev.Prompt, rev.Answer, rev.Transcription, hash,
+		rev.ChangedBy, rev.Source, rev.SourceRef, rev.Policy, rev.Affected).Scan(&number)
+	return number, err
+}
+
+// ApplyProgressPolicy применяет политику к прогрессу по предложению и возвращает число затронутых записей.
+func ApplyProgressPolicy(tx *sql.Tx, sentenceID int, policy string) (int64, error) {
+	var res sql.Result
+	var err error
+	switch policy {
+	case PolicyKeep:
+		return 0, nil
+	case PolicyDowngrade:
+		res, err = tx.Exec(`UPDATE user_progress SET status = 'learning', correct_streak = 0, next_review_date = NOW(), updated_at = NOW()
+			WHERE sentence_id = $1 AND status = 'mastered'`, sentenceID)
+	case PolicyReset:
+		res, err = tx.Exec("DELETE FROM user_progress WHERE sentence_id = $1", sentenceID)
+	default:
+		return 0, fmt.Errorf("content: unknown progress policy %q", policy)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ProgressUsers - ученики, чьи записи user_progress изменит политика (для отчета до ее применения).
+func ProgressUsers(tx *sql.Tx, sentenceID int, policy string) ([]int, error) {
+	var query string
+	switch policy {
+	case PolicyKeep:
+		return nil, nil
+	case PolicyDowngrade:
+		query = "SELECT user_id FROM user_progress WHERE sentence_id = $1 AND status = 'mastered'"
+	case PolicyReset:
+		query = "SELECT user_id FROM user_progress WHERE sentence_id = $1"
+	default:
+		return nil, fmt.Errorf("content: unknown progress policy %q", policy)
+	}
+	rows, err := tx.Query(query, sentenceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,76 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// Sender - всё, что умеет отправить письмо пользователю.
+type Sender interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPSender отправляет письма через обычный SMTP-сервер.
+type SMTPSender struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+}
+
+func (s *SMTPSender) Send(to, subject, body string) error {
+	msg := strings.Join([]string{
+		"From: " + s.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	var auth smtp.Auth
+	if s.User != "" {
+		auth = smtp.PlainAuth("", s.User, s.Password, s.Host)
+	}
+	if err := smtp.SendMail(s.Host+":"+s.Port, auth, s.From, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("smtp send to %s: %w", to, err)
+	}
+	return nil
+}
+
+// LogSender ничего не отправляет, а пишет письмо в лог. Удобно для локальной разработки.
+type LogSender struct{}
+
+func (LogSender) Send(to, subject, body string) error {
+	log.Printf("MAIL to=%s subject=%q\n%s", to, subject, body)
+	return nil
+}
+
+// NewFromEnv создает Sender по переменным окружения SMTP_*.
+// Если SMTP_HOST не задан, письма просто пишутся в лог.
+func NewFromEnv() Sender {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return LogSender{}
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "no-reply@lingo-sprint.local"
+	}
+	return &SMTPSender{
+		Host:     host,
+		Port:     port,
+		User:     os.Getenv("SMTP_USER"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}
+}
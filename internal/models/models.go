@@ -1,11 +1,28 @@
 package models
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
+
+// Course - языковая пара: ученик переводит с SourceLanguage на TargetLanguage
+type Course struct {
+	ID             int    `json:"id"`
+	Code           string `json:"code"` // "ru-en"
+	Title          string `json:"title"`
+	SourceLanguage string `json:"source_language"`
+	TargetLanguage string `json:"target_language"`
+	TTSLanguage    string `json:"tts_language"` // "en-US": и для mp3, и для озвучки в браузере
+	TTSVoice       string `json:"tts_voice"`
+	SortOrder      int    `json:"sort_order"`
+}
 
 // Level представляет один уровень (A0, A1...)
 type Level struct {
-	ID    int    `json:"id"`
-	Title string `json:"title"`
+	ID          int    `json:"id"`
+	CourseID    int    `json:"course_id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
 }
 
 // Lesson представляет один урок
@@ -15,6 +32,14 @@ type Lesson struct {
 	LessonNumber int    `json:"lesson_number"`
 	Title        string `json:"title"`
 
+	// Из манифеста курса (scripts/data_loader)
+	Description   string   `json:"description,omitempty"`
+	GrammarTopics []string `json:"grammar_topics,omitempty"`
+
+	// Публикация (заполняется только в админских ответах)
+	Status    string     `json:"status,omitempty"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+
 	// Данные для прогресса
 	TotalSentences     int `json:"total_sentences"`
 	CompletedSentences int `json:"completed_sentences"`
@@ -29,12 +54,32 @@ type Sentence struct {
 	ID          int    `json:"id"`
 	LessonID    int    `json:"lesson_id"`
 	OrderNumber int    `json:"order_number"`
-	PromptRU    string `json:"prompt_ru"`
-	AnswerEN    string `json:"answer_en"`
+	Prompt      string `json:"prompt"` // на языке ученика (Course.SourceLanguage)
+	Answer      string `json:"answer"` // на изучаемом языке (Course.TargetLanguage)
 
 	Transcription sql.NullString `json:"transcription"`
 	AudioPath     sql.NullString `json:"audio_path"`
 
 	Status        sql.NullString `json:"status"`
 	CorrectStreak sql.NullInt32  `json:"correct_streak"`
-}
\ No newline at end of file
+}
+
+// NotificationPrefs - какие письма/напоминания пользователь хочет получать
+type NotificationPrefs struct {
+	DailyReminder bool `json:"daily_reminder"`
+	WeeklyReport  bool `json:"weekly_report"`
+	ProductNews   bool `json:"product_news"`
+}
+
+// UserProfile - настройки пользователя, общие для всех его устройств
+type UserProfile struct {
+	DisplayName       string            `json:"display_name"`
+	NativeLanguage    string            `json:"native_language"`
+	TargetLanguage    string            `json:"target_language"`
+	TimeZone          string            `json:"time_zone"`
+	DailyGoal         int               `json:"daily_goal"` // предложений в день
+	PreferredTTSVoice string            `json:"preferred_tts_voice"`
+	UILocale          string            `json:"ui_locale"`
+	Notifications     NotificationPrefs `json:"notifications"`
+	ActiveCourseID    *int              `json:"active_course_id"` // nil - курс по умолчанию
+}
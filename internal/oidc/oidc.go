@@ -0,0 +1,427 @@
+// Package oidc - вход через внешних провайдеров (OpenID Connect, authorization code + PKCE).
+//
+// Проверка на локальном mock-провайдере: в docker-compose.yml есть сервис mock-oidc
+// (mock-oauth2-server), который отдает discovery, JWKS и токены по обычному HTTP:
+//
+//	docker compose --profile oidc-mock up -d mock-oidc db
+//	OIDC_PROVIDERS='[{"name":"mock","issuer":"http://localhost:8081/default","client_id":"lingo","client_secret":"secret"}]' go run ./cmd/server
+//
+// На форме входа mock-сервера в поле claims указывается, что "вернет" провайдер, например
+// {"sub":"u1","email":"ivan@example.com","email_verified":true}. Так проверяются все ветки
+// resolveIdentity (internal/api/oidc.go): новый аккаунт, привязка к подтвержденному email,
+// перехват аккаунта с неподтвержденным email и отказ при email_verified=false.
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ProviderConfig - один внешний провайдер входа. Обычно достаточно issuer,
+// client_id и client_secret: остальное берется из discovery-документа.
+type ProviderConfig struct {
+	Name         string   `json:"name"` // используется в URL: /api/auth/oidc/{name}/start
+	DisplayName  string   `json:"display_name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+
+	// Для провайдеров без discovery (например, Яндекс ID) эндпоинты задаются явно
+	AuthURL     string `json:"authorization_endpoint"`
+	TokenURL    string `json:"token_endpoint"`
+	UserInfoURL string `json:"userinfo_endpoint"`
+	JWKSURL     string `json:"jwks_uri"`
+
+	SubjectClaim string `json:"subject_claim"` // по умолчанию "sub"
+	EmailClaim   string `json:"email_claim"`   // по умолчанию "email"
+	TrustEmail   bool   `json:"trust_email"`   // провайдер не отдает email_verified, но проверяет адреса сам
+}
+
+// Identity - то, что мы узнали о пользователе от провайдера.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// Provider - клиент одного провайдера с кешем discovery и ключей подписи.
+type Provider struct {
+	Config ProviderConfig
+
+	client     *http.Client
+	mu         sync.Mutex
+	discovered bool
+	keys       map[string]interface{}
+	keysAt     time.Time
+}
+
+const jwksCacheTTL = time.Hour
+
+// LoadProviders разбирает JSON-массив конфигураций (переменная OIDC_PROVIDERS).
+func LoadProviders(raw string) (map[string]*Provider, error) {
+	providers := map[string]*Provider{}
+	if strings.TrimSpace(raw) == "" {
+		return providers, nil
+	}
+	var configs []ProviderConfig
+	if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+		return nil, fmt.Errorf("parse OIDC providers: %w", err)
+	}
+	for _, c := range configs {
+		if c.Name == "" || c.ClientID == "" {
+			return nil, fmt.Errorf("OIDC provider %q: name and client_id are required", c.Name)
+		}
+		if c.Issuer == "" && (c.AuthURL == "" || c.TokenURL == "") {
+			return nil, fmt.Errorf("OIDC provider %q: issuer or explicit endpoints are required", c.Name)
+		}
+		if len(c.Scopes) == 0 {
+			c.Scopes = []string{"openid", "email", "profile"}
+		}
+		if c.SubjectClaim == "" {
+			c.SubjectClaim = "sub"
+		}
+		if c.EmailClaim == "" {
+			c.EmailClaim = "email"
+		}
+		if c.DisplayName == "" {
+			c.DisplayName = c.Name
+		}
+		providers[c.Name] = &Provider{Config: c, client: &http.Client{Timeout: 15 * time.Second}}
+	}
+	return providers, nil
+}
+
+// NewPKCE возвращает code_verifier и code_challenge (S256) по RFC 7636.
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString - 32 случайных байта в base64url (для state, nonce, verifier).
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// discover подтягивает эндпоинты из /.well-known/openid-configuration (один раз).
+// Явно заданные в конфиге эндпоинты имеют приоритет.
+func (p *Provider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovered || p.Config.Issuer == "" {
+		return nil
+	}
+
+	var doc struct {
+		Issuer      string `json:"issuer"`
+		AuthURL     string `json:"authorization_endpoint"`
+		TokenURL    string `json:"token_endpoint"`
+		UserInfoURL string `json:"userinfo_endpoint"`
+		JWKSURL     string `json:"jwks_uri"`
+	}
+	wellKnown := strings.TrimRight(p.Config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, "", &doc); err != nil {
+		return fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != strings.TrimRight(p.Config.Issuer, "/") {
+		return fmt.Errorf("discovery: issuer mismatch: %q != %q", doc.Issuer, p.Config.Issuer)
+	}
+	if p.Config.AuthURL == "" {
+		p.Config.AuthURL = doc.AuthURL
+	}
+	if p.Config.TokenURL == "" {
+		p.Config.TokenURL = doc.TokenURL
+	}
+	if p.Config.UserInfoURL == "" {
+		p.Config.UserInfoURL = doc.UserInfoURL
+	}
+	if p.Config.JWKSURL == "" {
+		p.Config.JWKSURL = doc.JWKSURL
+	}
+	p.discovered = true
+	return nil
+}
+
+// AuthCodeURL строит ссылку на страницу входа провайдера (authorization code + PKCE).
+func (p *Provider) AuthCodeURL(ctx context.Context, redirectURI, state, nonce, codeChallenge string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.Config.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", strings.Join(p.Config.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.Config.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.Config.AuthURL + sep + q.Encode(), nil
+}
+
+// Exchange меняет code на токены, проверяет id_token и возвращает личность пользователя.
+func (p *Provider) Exchange(ctx context.Context, code, redirectURI, codeVerifier, nonce string) (*Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", p.Config.ClientID)
+	form.Set("client_secret", p.Config.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.Config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, body)
+	}
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("token response: %w", err)
+	}
+
+	claims := map[string]interface{}{}
+	if tokens.IDToken != "" {
+		if claims, err = p.verifyIDToken(ctx, tokens.IDToken, nonce); err != nil {
+			return nil, err
+		}
+	}
+	// Без id_token (или без email в нем) спрашиваем userinfo
+	if (tokens.IDToken == "" || claimString(claims, p.Config.EmailClaim) == "") && p.Config.UserInfoURL != "" && tokens.AccessToken != "" {
+		info := map[string]interface{}{}
+		if err := p.getJSON(ctx, p.Config.UserInfoURL, tokens.AccessToken, &info); err != nil {
+			return nil, fmt.Errorf("userinfo: %w", err)
+		}
+		if tokens.IDToken != "" && claimString(info, p.Config.SubjectClaim) != claimString(claims, p.Config.SubjectClaim) {
+			return nil, errors.New("userinfo subject does not match id_token")
+		}
+		for k, v := range info {
+			if _, exists := claims[k]; !exists {
+				claims[k] = v
+			}
+		}
+	}
+
+	id := &Identity{
+		Provider: p.Config.Name,
+		Subject:  claimString(claims, p.Config.SubjectClaim),
+		Email:    claimString(claims, p.Config.EmailClaim),
+	}
+	if id.Subject == "" {
+		return nil, errors.New("provider did not return a subject")
+	}
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		id.EmailVerified = v
+	case string:
+		id.EmailVerified = v == "true"
+	}
+	if p.Config.TrustEmail && id.Email != "" {
+		id.EmailVerified = true
+	}
+	return id, nil
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, raw, nonce string) (map[string]interface{}, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithAudience(p.Config.ClientID),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	}
+	if p.Config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(p.Config.Issuer))
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("id_token: %w", err)
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("id_token: nonce mismatch")
+	}
+	return claims, nil
+}
+
+// key ищет ключ подписи по kid. Неизвестный kid - повод перечитать JWKS (ротация ключей).
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k, ok := p.lookupKey(kid); ok && time.Since(p.keysAt) < jwksCacheTTL {
+		return k, nil
+	}
+	if p.Config.JWKSURL == "" {
+		return nil, errors.New("provider has no jwks_uri")
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.Config.JWKSURL, "", &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	p.keys = map[string]interface{}{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		p.keys[k.Kid] = pub
+	}
+	p.keysAt = time.Now()
+
+	if k, ok := p.lookupKey(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("signing key %q not found", kid)
+}
+
+func (p *Provider) lookupKey(kid string) (interface{}, bool) {
+	if k, ok := p.keys[kid]; ok {
+		return k, true
+	}
+	// Токен без kid допустим, только если ключ у провайдера один
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func (p *Provider) getJSON(ctx context.Context, rawURL, bearer string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", rawURL, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+func claimString(claims map[string]interface{}, name string) string {
+	switch v := claims[name].(type) {
+	case string:
+		return v
+	case float64:
+		// Некоторые провайдеры отдают числовой id
+		return fmt.Sprintf("%.0f", v)
+	}
+	return ""
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -19,8 +21,10 @@ import (
 
 // Sentence - упрощенная структура для нашей задачи
 type Sentence struct {
-	ID       int
-	AnswerEN string
+	ID          int
+	Answer      string
+	TTSLanguage string // из курса предложения: "en-US", "de-DE"
+	TTSVoice    string // "en-US-Standard-F"
 }
 
 // === Настройки ===
@@ -110,7 +114,9 @@ func main() {
 
 // getSentencesToProcess получает все предложения, где audio_path пуст
 func getSentencesToProcess(db *sql.DB) ([]Sentence, error) {
-	rows, err := db.Query("SELECT id, answer_en FROM sentences WHERE audio_path IS NULL OR audio_path = ''")
+	rows, err := db.Query(`SELECT s.id, s.answer, c.tts_language, c.tts_voice FROM sentences s
+		JOIN lessons l ON l.id = s.lesson_id JOIN levels lv ON lv.id = l.level_id JOIN courses c ON c.id = lv.course_id
+		WHERE s.archived_at IS NULL AND (s.audio_path IS NULL OR s.audio_path = '')`)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +125,7 @@ func getSentencesToProcess(db *sql.DB) ([]Sentence, error) {
 	var sentences []Sentence
 	for rows.Next() {
 		var s Sentence
-		if err := rows.Scan(&s.ID, &s.AnswerEN); err != nil {
+		if err := rows.Scan(&s.ID, &s.Answer, &s.TTSLanguage, &s.TTSVoice); err != nil {
 			log.Printf("Ошибка сканирования строки: %v", err)
 			continue
 		}
@@ -133,22 +139,29 @@ func worker(ctx context.Context, wg *sync.WaitGroup, client *texttospeech.Client
 	defer wg.Done()
 
 	for s := range jobs {
-		filePath := filepath.Join(outputDir, fmt.Sprintf("%d.mp3", s.ID))
+		fileName := audioFileName(s)
+		filePath := filepath.Join(outputDir, fileName)
 		
 		// 1. Генерируем аудио
-		err := synthesizeAndSave(ctx, client, s.AnswerEN, filePath)
+		err := synthesizeAndSave(ctx, client, s, filePath)
 		if err != nil {
 			log.Printf("Ошибка (ID %d): Не удалось сгенерировать: %v", s.ID, err)
 			continue
 		}
 
-		// 2. Обновляем путь в БД
-		dbPath := fmt.Sprintf("media/%d.mp3", s.ID)
-		_, err = db.Exec("UPDATE sentences SET audio_path = $1 WHERE id = $2", dbPath, s.ID)
+		// 2. Обновляем путь в БД - только если озвучен текущий ответ: если его поправили,
+		// пока шла генерация, audio_path уже сброшен и предложение озвучится при следующем запуске
+		dbPath := "media/" + fileName
+		res, err := db.Exec("UPDATE sentences SET audio_path = $1 WHERE id = $2 AND answer = $3", dbPath, s.ID, s.Answer)
 		if err != nil {
 			log.Printf("Ошибка (ID %d): Не удалось обновить БД: %v", s.ID, err)
 			continue
 		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			log.Printf("Пропуск (ID %d): ответ изменился во время озвучки", s.ID)
+			os.Remove(filePath)
+			continue
+		}
 
 		results <- fmt.Sprintf("Успех: ID %d -> %s", s.ID, dbPath)
 
@@ -159,19 +172,25 @@ func worker(ctx context.Context, wg *sync.WaitGroup, client *texttospeech.Client
 	}
 }
 
+// audioFileName - имя .mp3 с хешем озвученного текста и голоса: после правки ответа или смены
+// голоса у файла новое имя, и браузеры не берут из кэша старую озвучку
+func audioFileName(s Sentence) string {
+	sum := sha256.Sum256([]byte(s.TTSVoice + "\x1f" + s.Answer))
+	return fmt.Sprintf("%d-%s.mp3", s.ID, hex.EncodeToString(sum[:])[:12])
+}
+
 // synthesizeAndSave вызывает Google API и сохраняет .mp3 файл
-func synthesizeAndSave(ctx context.Context, client *texttospeech.Client, text, outputPath string) error {
+func synthesizeAndSave(ctx context.Context, client *texttospeech.Client, s Sentence, outputPath string) error {
 	req := &texttospeechpb.SynthesizeSpeechRequest{
 		Input: &texttospeechpb.SynthesisInput{
-			InputSource: &texttospeechpb.SynthesisInput_Text{Text: text},
+			InputSource: &texttospeechpb.SynthesisInput_Text{Text: s.Answer},
 		},
-		// --- ГОЛОС (en-US, Standard, 'F') ---
-		// Вы можете выбрать любой другой "Standard" (не "Wavenet") голос
-		// для бесплатного лимита.
+		// --- ГОЛОС курса (courses.tts_voice) ---
+		// Для бесплатного лимита выбирайте "Standard" (не "Wavenet") голоса.
+		// Пол задает сам голос по имени, поэтому SsmlGender не указываем.
 		Voice: &texttospeechpb.VoiceSelectionParams{
-			LanguageCode: "en-US",
-			SsmlGender:   texttospeechpb.SsmlVoiceGender_FEMALE,
-			Name:         "en-US-Standard-F",
+			LanguageCode: s.TTSLanguage,
+			Name:         s.TTSVoice,
 		},
 		AudioConfig: &texttospeechpb.AudioConfig{
 			AudioEncoding: texttospeechpb.AudioEncoding_MP3,
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+)
+
+// Инкрементальная загрузка. Для каждого урока в lesson_sources запоминается контрольная сумма
+// строк источника и отпечаток предложений урока в БД после загрузки. Если при следующем запуске
+// не изменилось ни то ни другое, урок пропускается целиком, без построчной сверки.
+// Отпечаток нужен, чтобы правки в админке или через API не "залипали": если урок в БД поменяли
+// после загрузки, он будет сверен с файлом заново, даже если сам файл тот же. -force сверяет все.
+
+// sourceChecksum - sha256 строк урока. Считается по разобранным строкам, а не по байтам файла,
+// поэтому одинаково работает для CSV, листа XLSX и JSON-курса
+func sourceChecksum(records []csvRecord) string {
+	h := sha256.New()
+	for _, rec := range records {
+		h.Write([]byte(strings.Join(rec.Fields, "\x1f")))
+		h.Write([]byte{'\x1e'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lessonFingerprintSQL - отпечаток активных предложений урока ($1 - lesson_id): ключи, порядок и content_hash
+const lessonFingerprintSQL = `SELECT encode(sha256(convert_to(COALESCE(string_agg(
+		COALESCE(external_id, '') || chr(31) || order_number || chr(31) || COALESCE(content_hash, ''), chr(30) ORDER BY order_number), ''), 'UTF8')), 'hex')
+	FROM sentences WHERE lesson_id = $1 AND archived_at IS NULL`
+
+// lessonUnchanged - урок загружен из тех же строк и с тех пор в БД не менялся
+func lessonUnchanged(tx *sql.Tx, lessonID int, checksum string) (bool, error) {
+	var unchanged bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM lesson_sources
+		WHERE lesson_id = $1 AND checksum = $2 AND fingerprint = (`+lessonFingerprintSQL+`))`, lessonID, checksum).Scan(&unchanged)
+	return unchanged, err
+}
+
+// saveLessonSource запоминает, из чего и в каком состоянии урок загружен
+func saveLessonSource(tx *sql.Tx, lessonID int, sourceRef, checksum string) error {
+	_, err := tx.Exec(`INSERT INTO lesson_sources (lesson_id, source_ref, checksum, fingerprint, loaded_at)
+		VALUES ($1, $2, $3, (`+lessonFingerprintSQL+`), NOW())
+		ON CONFLICT (lesson_id) DO UPDATE SET source_ref = EXCLUDED.source_ref, checksum = EXCLUDED.checksum,
+			fingerprint = EXCLUDED.fingerprint, loaded_at = EXCLUDED.loaded_at`, lessonID, sourceRef, checksum)
+	return err
+}
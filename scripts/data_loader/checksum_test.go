@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestSourceChecksum(t *testing.T) {
+	base := []csvRecord{
+		{Line: 2, Fields: []string{"1", "Привет", "Hello"}},
+		{Line: 3, Fields: []string{"2", "Пока", "Bye", "baɪ"}},
+	}
+	sum := sourceChecksum(base)
+	if len(sum) != 64 {
+		t.Fatalf("sourceChecksum: длина %d, ожидался hex SHA-256", len(sum))
+	}
+
+	tests := []struct {
+		name    string
+		records []csvRecord
+		same    bool
+	}{
+		{"те же строки", []csvRecord{
+			{Line: 2, Fields: []string{"1", "Привет", "Hello"}},
+			{Line: 3, Fields: []string{"2", "Пока", "Bye", "baɪ"}},
+		}, true},
+		// Номер строки - только для сообщений: пустая строка в файле не меняет урок
+		{"другие номера строк", []csvRecord{
+			{Line: 5, Fields: []string{"1", "Привет", "Hello"}},
+			{Line: 9, Fields: []string{"2", "Пока", "Bye", "baɪ"}},
+		}, true},
+		{"другой порядок", []csvRecord{
+			{Line: 2, Fields: []string{"2", "Пока", "Bye", "baɪ"}},
+			{Line: 3, Fields: []string{"1", "Привет", "Hello"}},
+		}, false},
+		{"изменен ответ", []csvRecord{
+			{Line: 2, Fields: []string{"1", "Привет", "Hi"}},
+			{Line: 3, Fields: []string{"2", "Пока", "Bye", "baɪ"}},
+		}, false},
+		// Разделители не дают склеить соседние поля или строки в одинаковый текст
+		{"поле перенесено в соседнее", []csvRecord{
+			{Line: 2, Fields: []string{"1", "ПриветHello", ""}},
+			{Line: 3, Fields: []string{"2", "Пока", "Bye", "baɪ"}},
+		}, false},
+		{"строки объединены", []csvRecord{
+			{Line: 2, Fields: []string{"1", "Привет", "Hello", "2", "Пока", "Bye", "baɪ"}},
+		}, false},
+		{"без транскрипции", []csvRecord{
+			{Line: 2, Fields: []string{"1", "Привет", "Hello"}},
+			{Line: 3, Fields: []string{"2", "Пока", "Bye"}},
+		}, false},
+	}
+	for _, tt := range tests {
+		if got := sourceChecksum(tt.records) == sum; got != tt.same {
+			t.Errorf("%s: совпадение контрольной суммы = %v, want %v", tt.name, got, tt.same)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Курс (языковая пара), в который идет загрузка. Уровни ищутся и создаются только в нем,
+// поэтому A1 курса ru-en и A1 курса ru-de - разные уровни. Курс задается в манифесте
+// ("course": {"code": "uk-en", ...}) или флагом -course; без того и другого - ru-en,
+// курс, к которому относится весь контент, загруженный до появления курсов.
+const defaultCourseCode = "ru-en"
+
+// manifestCourse - поле "course" манифеста. Старые манифесты пишут туда только название
+// строкой ("course": "Русский → English"), это тоже принимается.
+type manifestCourse struct {
+	Code           string `json:"code,omitempty"` // "ru-en"
+	Title          string `json:"title,omitempty"`
+	SourceLanguage string `json:"source_language,omitempty"` // ISO 639: "ru"
+	TargetLanguage string `json:"target_language,omitempty"` // "en"
+	TTSLanguage    string `json:"tts_language,omitempty"`    // "en-US"
+	TTSVoice       string `json:"tts_voice,omitempty"`       // "en-US-Standard-F"
+}
+
+func (c *manifestCourse) UnmarshalJSON(data []byte) error {
+	var title string
+	if err := json.Unmarshal(data, &title); err == nil {
+		*c = manifestCourse{Title: title}
+		return nil
+	}
+	type plain manifestCourse
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("course должен быть строкой или объектом: %v", err)
+	}
+	*c = manifestCourse(p)
+	return nil
+}
+
+var (
+	courseCodeRegexp   = regexp.MustCompile(`^([a-z]{2,3})-([a-z]{2,3})$`)              // "ru-en"
+	languageRegexp     = regexp.MustCompile(`^[a-z]{2,3}$`)                             // как в API (profile.go)
+	ttsLanguageRegexp  = regexp.MustCompile(`^[a-z]{2,3}-[A-Z]{2}$`)                    // "en-US"
+	ttsVoiceNameRegexp = regexp.MustCompile(`^[a-z]{2,3}-[A-Z]{2}-[A-Za-z0-9-]{1,48}$`) // "en-US-Standard-F"
+)
+
+// resolveCourse сводит курс из манифеста и флага -course. Языки, если их не указали,
+// берутся из кода курса: "uk-en" - с украинского на английский
+func resolveCourse(fromManifest manifestCourse, flagCode string) (manifestCourse, error) {
+	c := fromManifest
+	switch {
+	case c.Code == "" && flagCode == "":
+		c.Code = defaultCourseCode
+	case c.Code == "":
+		c.Code = flagCode
+	case flagCode != "" && flagCode != c.Code:
+		return c, fmt.Errorf("в манифесте курс %s, а в -course - %s", c.Code, flagCode)
+	}
+	m := courseCodeRegexp.FindStringSubmatch(c.Code)
+	if m == nil {
+		return c, fmt.Errorf("код курса %q должен быть вида ru-en", c.Code)
+	}
+	if c.SourceLanguage == "" {
+		c.SourceLanguage = m[1]
+	}
+	if c.TargetLanguage == "" {
+		c.TargetLanguage = m[2]
+	}
+	if !languageRegexp.MatchString(c.SourceLanguage) || !languageRegexp.MatchString(c.TargetLanguage) {
+		return c, fmt.Errorf("курс %s: языки указываются кодами ISO 639 (ru, en)", c.Code)
+	}
+	if c.SourceLanguage == c.TargetLanguage {
+		return c, fmt.Errorf("курс %s: source_language и target_language совпадают", c.Code)
+	}
+	if c.TTSLanguage != "" && !ttsLanguageRegexp.MatchString(c.TTSLanguage) {
+		return c, fmt.Errorf("курс %s: tts_language должен быть вида en-US", c.Code)
+	}
+	if c.TTSVoice != "" && !ttsVoiceNameRegexp.MatchString(c.TTSVoice) {
+		return c, fmt.Errorf("курс %s: tts_voice должен быть вида en-US-Standard-F", c.Code)
+	}
+	return c, nil
+}
+
+// cyrillicLanguages - языки, которые пишутся кириллицей: в ответах остальных кириллица - опечатка
+var cyrillicLanguages = map[string]bool{"ru": true, "uk": true, "be": true, "bg": true, "sr": true, "mk": true, "kk": true}
+
+// getOrInsertCourse находит курс по коду или создает его. У существующего курса название
+// и озвучка берутся из манифеста, а языки менять нельзя: под них уже написаны предложения
+func getOrInsertCourse(tx *sql.Tx, c manifestCourse) (int, error) {
+	var id int
+	var old manifestCourse
+	err := tx.QueryRow(`SELECT id, title, source_language, target_language, tts_language, tts_voice
+		FROM courses WHERE code = $1`, c.Code).
+		Scan(&id, &old.Title, &old.SourceLanguage, &old.TargetLanguage, &old.TTSLanguage, &old.TTSVoice)
+	if err == sql.ErrNoRows {
+		if c.TTSLanguage == "" || c.TTSVoice == "" {
+			return 0, fmt.Errorf("курса %s нет в БД; чтобы создать его, укажите в манифесте course с tts_language и tts_voice", c.Code)
+		}
+		if c.Title = strings.TrimSpace(c.Title); c.Title == "" {
+			c.Title = c.Code
+		}
+		err = tx.QueryRow(`INSERT INTO courses (code, title, source_language, target_language, tts_language, tts_voice)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			c.Code, c.Title, c.SourceLanguage, c.TargetLanguage, c.TTSLanguage, c.TTSVoice).Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+		log.Printf(" -> Создан новый курс: '%s' (%s, ID: %d)", c.Title, c.Code, id)
+		report.Course = courseDiff{Code: c.Code, Added: true}
+		return id, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	report.Course = courseDiff{Code: c.Code}
+	if old.SourceLanguage != c.SourceLanguage || old.TargetLanguage != c.TargetLanguage {
+		return 0, fmt.Errorf("курс %s в БД: %s -> %s, в источнике: %s -> %s; языки курса менять нельзя",
+			c.Code, old.SourceLanguage, old.TargetLanguage, c.SourceLanguage, c.TargetLanguage)
+	}
+
+	// Пустое в манифесте не затирает то, что уже есть в БД
+	next := old
+	if title := strings.TrimSpace(c.Title); title != "" {
+		next.Title = title
+	}
+	if c.TTSLanguage != "" {
+		next.TTSLanguage = c.TTSLanguage
+	}
+	if c.TTSVoice != "" {
+		next.TTSVoice = c.TTSVoice
+	}
+	report.Course.Fields = diffFields(
+		fieldDiff{"title", old.Title, next.Title},
+		fieldDiff{"tts_language", old.TTSLanguage, next.TTSLanguage},
+		fieldDiff{"tts_voice", old.TTSVoice, next.TTSVoice},
+	)
+	if report.Course.Fields == nil {
+		return id, nil
+	}
+	// Новый голос: старые mp3 озвучены прежним, scripts/audio_generator переозвучит курс
+	if next.TTSVoice != old.TTSVoice || next.TTSLanguage != old.TTSLanguage {
+		log.Printf("   ! Голос курса %s изменен: аудио курса будет озвучено заново", c.Code)
+		if _, err := tx.Exec(`UPDATE sentences s SET audio_path = NULL FROM lessons l, levels lv
+			WHERE l.id = s.lesson_id AND lv.id = l.level_id AND lv.course_id = $1 AND s.audio_path IS NOT NULL`, id); err != nil {
+			return 0, err
+		}
+	}
+	_, err = tx.Exec("UPDATE courses SET title = $1, tts_language = $2, tts_voice = $3 WHERE id = $4",
+		next.Title, next.TTSLanguage, next.TTSVoice, id)
+	return id, err
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestResolveCourse(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest manifestCourse
+		flag     string
+		want     manifestCourse
+		wantErr  bool
+	}{
+		{
+			name: "без манифеста и флага - курс по умолчанию",
+			want: manifestCourse{Code: "ru-en", SourceLanguage: "ru", TargetLanguage: "en"},
+		},
+		{
+			name: "код из флага",
+			flag: "uk-en",
+			want: manifestCourse{Code: "uk-en", SourceLanguage: "uk", TargetLanguage: "en"},
+		},
+		{
+			name:     "манифест и флаг совпадают",
+			manifest: manifestCourse{Code: "ru-de", Title: "Немецкий"},
+			flag:     "ru-de",
+			want:     manifestCourse{Code: "ru-de", Title: "Немецкий", SourceLanguage: "ru", TargetLanguage: "de"},
+		},
+		{
+			name:     "языки из манифеста не перезаписываются кодом",
+			manifest: manifestCourse{Code: "ru-en", SourceLanguage: "ru", TargetLanguage: "en", TTSLanguage: "en-GB", TTSVoice: "en-GB-Standard-A"},
+			want:     manifestCourse{Code: "ru-en", SourceLanguage: "ru", TargetLanguage: "en", TTSLanguage: "en-GB", TTSVoice: "en-GB-Standard-A"},
+		},
+		{name: "манифест и флаг расходятся", manifest: manifestCourse{Code: "ru-en"}, flag: "uk-en", wantErr: true},
+		{name: "код не языковая пара", flag: "english", wantErr: true},
+		{name: "одинаковые языки", flag: "en-en", wantErr: true},
+		{name: "языки не ISO 639", manifest: manifestCourse{Code: "ru-en", SourceLanguage: "Russian"}, wantErr: true},
+		{name: "tts_language без региона", manifest: manifestCourse{Code: "ru-en", TTSLanguage: "en"}, wantErr: true},
+		{name: "tts_voice не того вида", manifest: manifestCourse{Code: "ru-en", TTSVoice: "Standard-F"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := resolveCourse(tt.manifest, tt.flag)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: ожидалась ошибка, получено %+v", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: resolveCourse = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
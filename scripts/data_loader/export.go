@@ -0,0 +1,250 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Выгрузка контента из БД обратно в формат загрузчика, чтобы правки из админки и API
+// можно было вернуть в git и CSV остались источником правды:
+//
+//	go run ./scripts/data_loader export -out scripts            // manifest.json + CSV на урок
+//	go run ./scripts/data_loader export -format json -out course.json
+//	go run ./scripts/data_loader export -course uk-en -out courses/uk-en
+//
+// Загрузка выгруженного ничего не меняет: уровни, уроки (вместе со статусом и датой
+// публикации) и предложения (кроме архивных) выгружаются со всеми полями, которые читает загрузчик.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("out", "", "каталог для manifest.json и CSV (-format csv) или файл JSON-курса (-format json)")
+	format := fs.String("format", "csv", "csv или json")
+	courseCode := fs.String("course", defaultCourseCode, "код выгружаемого курса")
+	fs.Parse(args)
+	if *out == "" {
+		log.Fatal("Не указан -out")
+	}
+	if *format != "csv" && *format != "json" {
+		log.Fatalf("Неизвестный формат: %s", *format)
+	}
+
+	db := openDB()
+	defer db.Close()
+
+	// Один снимок на всю выгрузку, даже если контент в это время правят
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatalf("Не удалось начать транзакцию: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+		log.Fatalf("Не удалось начать транзакцию: %v", err)
+	}
+
+	course, err := exportCourse(tx, *courseCode, *format == "json")
+	if err != nil {
+		log.Fatalf("Ошибка выгрузки: %v", err)
+	}
+	if *format == "json" {
+		err = writeManifest(*out, course)
+	} else {
+		err = writeCSVCourse(*out, course)
+	}
+	if err != nil {
+		log.Fatalf("Ошибка записи: %v", err)
+	}
+	log.Printf("--- УСПЕХ! --- Выгружено в %s", *out)
+}
+
+// exportedLesson - урок манифеста вместе с предложениями (для CSV они пишутся в отдельный файл)
+type exportedLesson struct {
+	level     string
+	lesson    *manifestLesson
+	sentences []manifestSentence
+}
+
+type exportedCourse struct {
+	manifest courseManifest
+	lessons  []exportedLesson
+}
+
+// exportCourse читает курс, его уровни, уроки и предложения. inline - предложения прямо
+// в манифесте (JSON-курс)
+func exportCourse(tx *sql.Tx, code string, inline bool) (exportedCourse, error) {
+	var course exportedCourse
+	c := &course.manifest.Course
+	var courseID int
+	err := tx.QueryRow(`SELECT id, code, title, source_language, target_language, tts_language, tts_voice
+		FROM courses WHERE code = $1`, code).
+		Scan(&courseID, &c.Code, &c.Title, &c.SourceLanguage, &c.TargetLanguage, &c.TTSLanguage, &c.TTSVoice)
+	if err == sql.ErrNoRows {
+		return course, fmt.Errorf("курса %s нет в БД", code)
+	}
+	if err != nil {
+		return course, err
+	}
+
+	levels, err := tx.Query("SELECT id, title, sort_order, COALESCE(description, '') FROM levels WHERE course_id = $1 ORDER BY sort_order, title", courseID)
+	if err != nil {
+		return course, err
+	}
+	var levelIDs []int
+	for levels.Next() {
+		var id int
+		var lv manifestLevel
+		var order int
+		if err := levels.Scan(&id, &lv.Title, &order, &lv.Description); err != nil {
+			levels.Close()
+			return course, err
+		}
+		lv.Order = &order
+		levelIDs = append(levelIDs, id)
+		course.manifest.Levels = append(course.manifest.Levels, lv)
+	}
+	levels.Close()
+	if err := levels.Err(); err != nil {
+		return course, err
+	}
+
+	for i, levelID := range levelIDs {
+		lv := &course.manifest.Levels[i]
+		lessons, err := exportLessons(tx, levelID)
+		if err != nil {
+			return course, fmt.Errorf("уровень %s: %v", lv.Title, err)
+		}
+		lv.Lessons = lessons
+	}
+
+	// Указатели берутся после того, как срезы уроков собраны окончательно
+	for i := range course.manifest.Levels {
+		lv := &course.manifest.Levels[i]
+		for j := range lv.Lessons {
+			course.lessons = append(course.lessons, exportedLesson{level: lv.Title, lesson: &lv.Lessons[j]})
+		}
+	}
+	for i := range course.lessons {
+		el := &course.lessons[i]
+		sentences, err := exportSentences(tx, courseID, el.level, el.lesson.Number)
+		if err != nil {
+			return course, fmt.Errorf("урок %s: %v", lessonKey(el.level, el.lesson.Number), err)
+		}
+		el.sentences = sentences
+		if inline {
+			el.lesson.Sentences = &el.sentences
+		}
+	}
+	return course, nil
+}
+
+func exportLessons(tx *sql.Tx, levelID int) ([]manifestLesson, error) {
+	rows, err := tx.Query(`SELECT lesson_number, COALESCE(title, ''), COALESCE(description, ''), grammar_topics, status, publish_at
+		FROM lessons WHERE level_id = $1 ORDER BY lesson_number`, levelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lessons := []manifestLesson{}
+	for rows.Next() {
+		var ls manifestLesson
+		var topics []byte
+		var publishAt sql.NullTime
+		if err := rows.Scan(&ls.Number, &ls.Title, &ls.Description, &topics, &ls.Status, &publishAt); err != nil {
+			return nil, err
+		}
+		ls.PublishAt = nullTimePtr(publishAt)
+		if err := json.Unmarshal(topics, &ls.GrammarTopics); err != nil {
+			return nil, fmt.Errorf("урок %d: grammar_topics: %v", ls.Number, err)
+		}
+		lessons = append(lessons, ls)
+	}
+	return lessons, rows.Err()
+}
+
+func exportSentences(tx *sql.Tx, courseID int, level string, lessonNum int) ([]manifestSentence, error) {
+	rows, err := tx.Query(`SELECT s.id, COALESCE(s.external_id, ''), s.prompt, s.answer, COALESCE(s.transcription, '')
+		FROM sentences s JOIN lessons l ON l.id = s.lesson_id JOIN levels lv ON lv.id = l.level_id
+		WHERE lv.course_id = $1 AND lv.title = $2 AND l.lesson_number = $3 AND s.archived_at IS NULL
+		ORDER BY s.order_number`, courseID, level, lessonNum)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sentences := []manifestSentence{}
+	for rows.Next() {
+		var id int
+		var s manifestSentence
+		if err := rows.Scan(&id, &s.SentenceID, &s.Prompt, &s.Answer, &s.Transcription); err != nil {
+			return nil, err
+		}
+		if s.SentenceID == "" {
+			// Без ключа загрузка примет предложение за новое, а старое уйдет в архив вместе с прогрессом
+			return nil, fmt.Errorf("у предложения ID %d нет sentence_id (примените scripts/init.sql)", id)
+		}
+		sentences = append(sentences, s)
+	}
+	return sentences, rows.Err()
+}
+
+// unsafeFileChars - все, что не стоит класть в имя файла
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// lessonFileName - имя CSV урока; для обычных уровней совпадает с тем, что ищет загрузчик без манифеста
+func lessonFileName(level string, lessonNum int) string {
+	return fmt.Sprintf("%s_lesson_%d.csv", strings.Trim(unsafeFileChars.ReplaceAllString(level, "_"), "_"), lessonNum)
+}
+
+// writeCSVCourse пишет manifest.json и по CSV на урок в каталог dir
+func writeCSVCourse(dir string, course exportedCourse) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	files := map[string]string{} // имя файла -> урок, на случай уровней, которые после очистки имени совпали
+	for _, el := range course.lessons {
+		name := lessonFileName(el.level, el.lesson.Number)
+		key := lessonKey(el.level, el.lesson.Number)
+		if prev, ok := files[name]; ok {
+			return fmt.Errorf("уроки %s и %s попадают в один файл %s", prev, key, name)
+		}
+		files[name] = key
+		el.lesson.File = name
+		if err := writeLessonCSV(filepath.Join(dir, name), el.sentences); err != nil {
+			return err
+		}
+		log.Printf("   -> %s: %d предложений", name, len(el.sentences))
+	}
+	return writeManifest(filepath.Join(dir, "manifest.json"), course)
+}
+
+// writeLessonCSV пишет урок в том же виде, что читает загрузчик
+func writeLessonCSV(path string, sentences []manifestSentence) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(file)
+	w.Write([]string{"sentence_id", "prompt", "answer", "transcription"})
+	for _, s := range sentences {
+		w.Write([]string{string(s.SentenceID), s.Prompt, s.Answer, s.Transcription})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeManifest(path string, course exportedCourse) error {
+	data, err := json.MarshalIndent(course.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
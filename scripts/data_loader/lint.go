@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Проверка CSV перед загрузкой. Ошибки останавливают загрузку всегда, предупреждения -
+// только с -strict, замечания (info) не мешают никогда. -lint проверяет файлы без подключения к БД.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+// Пороги для "подозрительно длинных" предложений: на экране тренажера больше не помещается
+const (
+	maxSentenceWords = 25
+	maxSentenceRunes = 200
+)
+
+type lintIssue struct {
+	Severity   string `json:"severity"`
+	Code       string `json:"code"`
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	SentenceID string `json:"sentence_id,omitempty"`
+	Message    string `json:"message"`
+}
+
+// promptSeen - где встретилась подсказка (для поиска дублей)
+type promptSeen struct {
+	file       string
+	line       int
+	lesson     string
+	sentenceID string
+}
+
+// lintFiles проверяет все файлы уроков курса и возвращает замечания в порядке файлов и строк
+func lintFiles(lessonFiles []lessonFile, course manifestCourse) ([]lintIssue, error) {
+	issues := []lintIssue{}
+	prompts := map[string]promptSeen{} // нормализованная подсказка -> первое появление
+	for _, lf := range lessonFiles {
+		records, err := lf.Source.Records()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", lf.Path, err)
+		}
+		file := filepath.Base(lf.Path)
+		lesson := lessonKey(lf.LevelName, lf.LessonNum)
+		ids := map[string]int{} // sentence_id -> строка
+		add := func(severity, code string, rec csvRecord, format string, args ...interface{}) {
+			issue := lintIssue{Severity: severity, Code: code, File: file, Line: rec.Line, Message: fmt.Sprintf(format, args...)}
+			if len(rec.Fields) > 0 {
+				issue.SentenceID = strings.TrimSpace(rec.Fields[0])
+			}
+			issues = append(issues, issue)
+		}
+
+		for _, rec := range records {
+			if len(rec.Fields) < 3 {
+				add(severityError, "short_row", rec, "мало столбцов (%d из 3): %v", len(rec.Fields), rec.Fields)
+				continue
+			}
+			sentenceID, prompt, answer := rec.Fields[0], rec.Fields[1], rec.Fields[2]
+			transcription := ""
+			if len(rec.Fields) > 3 {
+				transcription = rec.Fields[3]
+			}
+
+			empty := false
+			for _, f := range []struct{ name, value string }{{"sentence_id", sentenceID}, {"prompt", prompt}, {"answer", answer}} {
+				if strings.TrimSpace(f.value) == "" {
+					add(severityError, "empty_field", rec, "пустое поле %s", f.name)
+					empty = true
+				}
+			}
+			if empty {
+				continue
+			}
+			if prev, ok := ids[strings.TrimSpace(sentenceID)]; ok {
+				add(severityError, "duplicate_sentence_id", rec, "sentence_id уже встречался в строке %d", prev)
+			}
+			ids[strings.TrimSpace(sentenceID)] = rec.Line
+			if transcription != "" && strings.TrimSpace(transcription) == "" {
+				add(severityWarning, "empty_field", rec, "transcription из одних пробелов")
+			}
+
+			if !cyrillicLanguages[course.TargetLanguage] && hasCyrillic(answer) {
+				add(severityError, "cyrillic_in_answer", rec, "кириллица в answer (курс %s): %q", course.Code, answer)
+			}
+			if p, a := terminalPunct(prompt), terminalPunct(answer); p != a {
+				add(severityWarning, "punctuation_mismatch", rec, "в конце prompt %q, в конце answer %q", p, a)
+			}
+			if strings.TrimSpace(transcription) == "" {
+				add(severityWarning, "missing_transcription", rec, "нет транскрипции")
+			}
+			for _, f := range []struct{ name, value string }{{"prompt", prompt}, {"answer", answer}} {
+				if words, runes := len(strings.Fields(f.value)), len([]rune(f.value)); words > maxSentenceWords || runes > maxSentenceRunes {
+					add(severityWarning, "long_sentence", rec, "%s слишком длинное: %d слов, %d символов", f.name, words, runes)
+				}
+			}
+
+			key := normalizePrompt(prompt)
+			first, dup := prompts[key]
+			switch {
+			case !dup:
+				prompts[key] = promptSeen{file: file, line: rec.Line, lesson: lesson, sentenceID: strings.TrimSpace(sentenceID)}
+			case first.lesson == lesson:
+				add(severityWarning, "duplicate_prompt", rec, "та же подсказка, что в строке %d (sentence_id %s)", first.line, first.sentenceID)
+			default:
+				add(severityInfo, "duplicate_prompt_across_lessons", rec, "та же подсказка, что в %s:%d (урок %s)", first.file, first.line, first.lesson)
+			}
+		}
+	}
+	return issues, nil
+}
+
+// lintFailed - есть ли замечания, из-за которых загрузку нельзя продолжать
+func lintFailed(issues []lintIssue, strict bool) bool {
+	for _, issue := range issues {
+		if issue.Severity == severityError || (strict && issue.Severity == severityWarning) {
+			return true
+		}
+	}
+	return false
+}
+
+// logLintIssues печатает замечания и итог по уровням серьезности
+func logLintIssues(issues []lintIssue) {
+	counts := map[string]int{}
+	for _, issue := range issues {
+		counts[issue.Severity]++
+		log.Printf("   [%s] %s:%d %s: %s", issue.Severity, issue.File, issue.Line, issue.Code, issue.Message)
+	}
+	log.Printf("Проверка CSV: ошибок %d, предупреждений %d, замечаний %d",
+		counts[severityError], counts[severityWarning], counts[severityInfo])
+}
+
+func hasCyrillic(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// terminalPunct - знак в конце предложения ("." "?" "!" "..."), без закрывающих кавычек и скобок;
+// пусто, если предложение кончается не знаком препинания
+func terminalPunct(s string) string {
+	s = strings.TrimRight(strings.TrimSpace(s), `"'»”’)]`)
+	s = strings.ReplaceAll(s, "…", "...")
+	switch {
+	case strings.HasSuffix(s, "..."):
+		return "..."
+	case strings.HasSuffix(s, "?!"), strings.HasSuffix(s, "!?"):
+		return "?!"
+	case strings.HasSuffix(s, "."), strings.HasSuffix(s, "?"), strings.HasSuffix(s, "!"):
+		return s[len(s)-1:]
+	}
+	return ""
+}
+
+// normalizePrompt - подсказка без регистра, лишних пробелов и знаков в конце
+func normalizePrompt(s string) string {
+	s = strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	return strings.TrimRightFunc(s, unicode.IsPunct)
+}
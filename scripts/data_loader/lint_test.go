@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestTerminalPunct(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Hello.", "."},
+		{"Are you sure?", "?"},
+		{"Stop!", "!"},
+		{"Really?!", "?!"},
+		{"What!?", "?!"},
+		{"Wait...", "..."},
+		{"Wait…", "..."},
+		{`He said "Go!"`, "!"},
+		{"«Привет.»", "."},
+		{"(see above.)", "."},
+		{"  Hi?  ", "?"},
+		{"No punctuation", ""},
+		{"Comma,", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := terminalPunct(tt.in); got != tt.want {
+			t.Errorf("terminalPunct(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizePrompt(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Я люблю кофе.", "я люблю кофе"},
+		{"  Я   люблю\tкофе  ", "я люблю кофе"},
+		{"ПРИВЕТ!", "привет"},
+		{"Ты готов?!", "ты готов"},
+		{"Ну, привет...", "ну, привет"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizePrompt(tt.in); got != tt.want {
+			t.Errorf("normalizePrompt(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
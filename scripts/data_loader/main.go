@@ -4,116 +4,289 @@ import (
 	"context"
 	"database/sql"
 	"encoding/csv"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/jackc/pgx/v5/stdlib" // Ваш драйвер БД
+
+	"lingo-sprint/internal/content"
 )
 
 const scriptsDir = "scripts" // Папка, где лежат CSV
 
+// archivedOrderOffset - архивные предложения получают order_number = -(id + archivedOrderOffset).
+// Отрицательные номера ближе к нулю - рабочее пространство перестановок: здесь временный -id,
+// в API (renumberSentences) - -order_number, и с архивом они не пересекаются (см. scripts/init.sql).
+const archivedOrderOffset = 1000000000
+
 // Структура для сортировки файлов
 type lessonFile struct {
-	Path        string
+	Path        string         // файл, для листа книги - "книга.xlsx#лист" (в логах и sentence_revisions.source_ref)
+	Source      sentenceSource // откуда читать предложения (source.go)
 	LevelName   string // "A0", "A1"
 	LessonNum   int    // 1, 2, 10
 	LessonTitle string // "A0 Lesson 1"
+
+	// Метаданные из манифеста (manifest.go); без манифеста у существующих уровней и уроков не меняются
+	FromManifest     bool
+	LevelOrder       int
+	LevelDescription string
+	Description      string
+	GrammarTopics    []string
+	Status           string // пусто - статус не задан (новый урок получает статус по -publish)
+	PublishAt        *time.Time
 }
 
 func main() {
+	// Выгрузка из БД обратно в CSV/JSON (export.go)
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		runExport(os.Args[2:])
+		return
+	}
+
+	// Что делать с прогрессом учеников, если правильный ответ в CSV изменился по существу
+	policy := flag.String("progress-policy", content.DefaultPolicy, "прогресс при изменении ответа: keep, downgrade или reset")
+	manifestPath := flag.String("manifest", "", "JSON-манифест или JSON-курс (по умолчанию manifest.json в каталоге -source, если есть)")
+	sourcePath := flag.String("source", scriptsDir, "каталог с CSV/XLSX, книга XLSX (лист на урок) или JSON-курс")
+	// Курс (языковая пара), в который идет загрузка; в манифесте - поле course (course.go)
+	courseCode := flag.String("course", "", "код курса, например uk-en (по умолчанию из манифеста или "+defaultCourseCode+")")
+	// Предпросмотр: все изменения считаются в транзакции и откатываются, печатается отчет (report.go)
+	dryRun := flag.Bool("dry-run", false, "ничего не записывать, только показать изменения")
+	jsonReport := flag.Bool("json", false, "напечатать отчет об изменениях в stdout в JSON (для CI)")
+	// Проверка CSV (lint.go): ошибки останавливают загрузку всегда, предупреждения - с -strict
+	lintOnly := flag.Bool("lint", false, "только проверить CSV, без подключения к БД")
+	strict := flag.Bool("strict", false, "не загружать, если проверка CSV нашла предупреждения")
+	// Уроки, у которых не изменились ни строки источника, ни предложения в БД, пропускаются (checksum.go)
+	force := flag.Bool("force", false, "сверять все уроки, даже не изменившиеся с прошлой загрузки")
+	// Проверка staging против БД (staging.go): защита от случайной архивации урока целиком
+	maxArchive := flag.Float64("max-archive", 0.5, "доля предложений урока, которую можно отправить в архив (1 - без ограничения)")
+	// Новые уроки по умолчанию - черновики: уровень готовят заранее и выпускают в админке к дате
+	publish := flag.Bool("publish", false, "новые уроки сразу публиковать (по умолчанию - черновики)")
+	flag.Parse()
+	if !content.IsValidPolicy(*policy) {
+		log.Fatalf("Неизвестная политика прогресса: %s", *policy)
+	}
+	report = newLoadReport(*dryRun)
+
 	log.Println("Запуск загрузчика данных...")
 	startTime := time.Now()
 
-	// 1. Загружаем .env (из корня проекта)
-	if err := godotenv.Load(); err != nil {
-		log.Fatalf("Ошибка загрузки .env файла: %v", err)
+	// 0. Находим файлы уроков и проверяем их до любых изменений в БД
+	fromManifest, lessonFiles, err := resolveLessonFiles(*manifestPath, *sourcePath)
+	if err != nil {
+		log.Fatalf("Ошибка поиска файлов: %v", err)
+	}
+	course, err := resolveCourse(fromManifest, *courseCode)
+	if err != nil {
+		log.Fatalf("Ошибка курса: %v", err)
+	}
+	log.Printf("Курс: %s (%s -> %s)", course.Code, course.SourceLanguage, course.TargetLanguage)
+	if report.Lint, err = lintFiles(lessonFiles, course); err != nil {
+		log.Fatalf("Ошибка проверки CSV: %v", err)
+	}
+	logLintIssues(report.Lint)
+	failed := lintFailed(report.Lint, *strict)
+	if *lintOnly || failed {
+		if *jsonReport {
+			if err := report.writeJSON(os.Stdout); err != nil {
+				log.Fatalf("Не удалось записать отчет: %v", err)
+			}
+		}
+		if failed {
+			log.Fatal("--- ПРОВЕРКА CSV НЕ ПРОЙДЕНА --- Данные не загружены.")
+		}
+		return
 	}
 
-	// 2. Подключаемся к БД
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL не найден в .env")
+	// 1-2. Подключаемся к БД. Все шаги идут на одном соединении: на нем держится
+	// advisory lock и живут временные staging-таблицы (staging.go)
+	db := openDB()
+	defer db.Close()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		log.Fatalf("Не удалось получить соединение: %v", err)
 	}
-	db, err := sql.Open("pgx", dbURL)
+	defer conn.Close()
+	if err := lockLoader(ctx, conn); err != nil {
+		log.Fatalf("Загрузка не начата: %v", err)
+	}
+	defer unlockLoader(ctx, conn)
+
+	// 3. Пишем строки источников в staging и проверяем их против БД
+	log.Println("Запись в staging...")
+	if err := stageLessons(ctx, conn, lessonFiles); err != nil {
+		log.Fatalf("Ошибка записи в staging: %v", err)
+	}
+	issues, err := validateStaging(ctx, conn, course.Code, *maxArchive)
 	if err != nil {
-		log.Fatalf("Не удалось подключиться к БД: %v", err)
+		log.Fatalf("Ошибка проверки staging: %v", err)
 	}
-	defer db.Close()
-	if err := db.Ping(); err != nil {
-		log.Fatalf("БД недоступна: %v", err)
+	report.Lint = append(report.Lint, issues...)
+	if len(issues) > 0 {
+		logLintIssues(issues)
+		// В dry-run отчет все равно строится: видно, что именно уйдет в архив
+		if !*dryRun {
+			if *jsonReport {
+				if err := report.writeJSON(os.Stdout); err != nil {
+					log.Fatalf("Не удалось записать отчет: %v", err)
+				}
+			}
+			log.Fatal("--- ПРОВЕРКА STAGING НЕ ПРОЙДЕНА --- Данные не загружены.")
+		}
 	}
-	log.Println("Успешно подключен к БД.")
 
-	// 3. Начинаем транзакцию
-	ctx := context.Background()
-	tx, err := db.BeginTx(ctx, nil)
+	// 4. Применяем staging одной транзакцией
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		log.Fatalf("Не удалось начать транзакцию: %v", err)
 	}
 	// Если что-то пойдет не так, откатываем изменения
 	defer tx.Rollback()
+	if err := applyFromStaging(tx, lessonFiles); err != nil {
+		log.Fatalf("Не удалось переключить уроки на staging: %v", err)
+	}
 
-	// 4. Главная логика
-	if err := processData(tx); err != nil {
+	// 5. Главная логика
+	applyStart := time.Now()
+	newLessonStatus := "draft"
+	if *publish {
+		newLessonStatus = "published"
+	}
+	if err := processData(tx, course, lessonFiles, *policy, *force, newLessonStatus); err != nil {
 		log.Fatalf("Ошибка обработки данных: %v. \n--- ИЗМЕНЕНИЯ ОТКАТЫВАЮТСЯ ---", err)
 	}
 
-	// 5. Если все прошло успешно, сохраняем изменения
-	if err := tx.Commit(); err != nil {
-		log.Fatalf("Не удалось сохранить транзакцию: %v", err)
+	// Если все прошло успешно, сохраняем изменения (в dry-run - откатываем)
+	if *dryRun {
+		if err := tx.Rollback(); err != nil {
+			log.Fatalf("Не удалось откатить транзакцию: %v", err)
+		}
+		log.Printf("--- DRY-RUN --- \nИзменения посчитаны за %v и не сохранены.", time.Since(startTime))
+	} else {
+		if err := tx.Commit(); err != nil {
+			log.Fatalf("Не удалось сохранить транзакцию: %v", err)
+		}
+		if report.ContentVersion, err = contentVersion(ctx, conn); err != nil {
+			log.Printf("   ! Не удалось прочитать версию контента: %v", err)
+		}
+		log.Printf("--- УСПЕХ! --- \nДанные успешно загружены за %v (применение %v), версия контента: %d.",
+			time.Since(startTime), time.Since(applyStart), report.ContentVersion)
 	}
 
-	log.Printf("--- УСПЕХ! --- \nДанные успешно загружены за %v.", time.Since(startTime))
+	// 6. Отчет
+	if *jsonReport {
+		if err := report.writeJSON(os.Stdout); err != nil {
+			log.Fatalf("Не удалось записать отчет: %v", err)
+		}
+	} else if *dryRun {
+		report.writeText(os.Stdout)
+	}
 }
 
-func processData(tx *sql.Tx) error {
-	// 1. Найти и отсортировать все CSV файлы
-	log.Println("Поиск и сортировка CSV файлов...")
-	lessonFiles, err := findAndSortFiles(scriptsDir)
+// openDB загружает .env (из корня проекта) и подключается к БД
+func openDB() *sql.DB {
+	if err := godotenv.Load(); err != nil {
+		log.Fatalf("Ошибка загрузки .env файла: %v", err)
+	}
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("DATABASE_URL не найден в .env")
+	}
+	db, err := sql.Open("pgx", dbURL)
 	if err != nil {
-		return err
+		log.Fatalf("Не удалось подключиться к БД: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		log.Fatalf("БД недоступна: %v", err)
+	}
+	log.Println("Успешно подключен к БД.")
+	return db
+}
+
+func processData(tx *sql.Tx, course manifestCourse, lessonFiles []lessonFile, policy string, force bool, newLessonStatus string) error {
+	// 1. Получить (или создать) курс
+	courseID, err := getOrInsertCourse(tx, course)
+	if err != nil {
+		return fmt.Errorf("ошибка курса %s: %v", course.Code, err)
 	}
-	log.Printf("Найдено %d файлов уроков для обработки.", len(lessonFiles))
 
 	// 2. Обработать каждый файл
 	totalSentences := 0
 	for _, lf := range lessonFiles {
 		log.Printf("Обработка: %s (Уровень: %s, Урок: %d)", filepath.Base(lf.Path), lf.LevelName, lf.LessonNum)
+		report.startLesson(lf)
 
 		// 2a. Получить (или создать) ID уровня
-		levelID, err := getOrInsertLevel(tx, lf.LevelName)
+		levelID, err := getOrInsertLevel(tx, courseID, lf.LevelName)
 		if err != nil {
 			return fmt.Errorf("ошибка уровня %s: %v", lf.LevelName, err)
 		}
 
 		// 2b. Получить (или создать) ID урока
-		lessonID, err := getOrInsertLesson(tx, levelID, lf.LessonNum, lf.LessonTitle)
+		status := newLessonStatus
+		if lf.Status != "" {
+			status = lf.Status
+		}
+		lessonID, err := getOrInsertLesson(tx, levelID, lf.LessonNum, lf.LessonTitle, status, lf.PublishAt)
 		if err != nil {
 			return fmt.Errorf("ошибка урока %d (уровень %d): %v", lf.LessonNum, levelID, err)
 		}
 
-		// 2c. Загрузить предложения из CSV
-		count, err := loadSentences(tx, lessonID, lf.Path)
+		// Манифест - источник правды для порядка, названий и описаний
+		if lf.FromManifest {
+			if err := updateMetadata(tx, levelID, lessonID, lf); err != nil {
+				return fmt.Errorf("ошибка метаданных %s, урок %d: %v", lf.LevelName, lf.LessonNum, err)
+			}
+		}
+
+		// 2c. Загрузить предложения (из CSV, листа XLSX или JSON)
+		count, err := loadSentences(tx, lessonID, lf.Source, lf.Path, policy, force)
 		if err != nil {
 			return fmt.Errorf("ошибка загрузки %s: %v", lf.Path, err)
 		}
 		totalSentences += count
 	}
 
+	// 3. Уроки, для которых нет файла (остаются в БД как есть)
+	if err := reportRemovedLessons(tx, courseID); err != nil {
+		return err
+	}
+
 	log.Printf("Загрузка завершена. Всего обработано предложений: %d", totalSentences)
 	return nil
 }
 
-// findAndSortFiles находит, парсит и сортирует CSV
+// reportRemovedLessons добавляет в отчет уроки курса из БД, которых нет в источнике
+func reportRemovedLessons(tx *sql.Tx, courseID int) error {
+	rows, err := tx.Query(`SELECT lv.title, l.lesson_number, COALESCE(l.title, '') FROM lessons l
+		JOIN levels lv ON lv.id = l.level_id WHERE lv.course_id = $1 ORDER BY lv.title, l.lesson_number`, courseID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l lessonRef
+		if err := rows.Scan(&l.Level, &l.Lesson, &l.Title); err != nil {
+			return err
+		}
+		if !report.seen[lessonKey(l.Level, l.Lesson)] {
+			report.Lessons.Removed = append(report.Lessons.Removed, l)
+		}
+	}
+	return rows.Err()
+}
+
+// findAndSortFiles находит, парсит и сортирует CSV (A0_lesson_1.csv) и книги XLSX
+// (по листу на урок, loadWorkbook)
 func findAndSortFiles(dir string) ([]lessonFile, error) {
 	files, err := os.ReadDir(dir)
 	if err != nil {
@@ -121,57 +294,48 @@ func findAndSortFiles(dir string) ([]lessonFile, error) {
 	}
 
 	var lessonFiles []lessonFile
-	// Регулярное выражение для парсинга имени файла
-	// (A0|A1|A2|B1|B2)_lesson_([0-9]+).csv
-	r := regexp.MustCompile(`^(A0|A1|A2|B1|B2)_lesson_([0-9]+)\.csv$`)
-
 	for _, file := range files {
 		if file.IsDir() {
 			continue
 		}
 
 		fileName := file.Name()
-		matches := r.FindStringSubmatch(fileName)
-
-		// 0: A0_lesson_1.csv
-		// 1: A0
-		// 2: 1
-		if len(matches) == 3 {
-			levelName := matches[1]
-			lessonNum, _ := strconv.Atoi(matches[2]) // Ошибка Atoi здесь маловероятна
-			title := fmt.Sprintf("%s - Урок %d", levelName, lessonNum)
-
-			lessonFiles = append(lessonFiles, lessonFile{
-				Path:        filepath.Join(dir, fileName),
-				LevelName:   levelName,
-				LessonNum:   lessonNum,
-				LessonTitle: title,
-			})
+		path := filepath.Join(dir, fileName)
+		ext := strings.ToLower(filepath.Ext(fileName))
+		switch {
+		case ext == ".xlsx" && !strings.HasPrefix(fileName, "~$"): // ~$ - временный файл открытой в Excel книги
+			sheets, err := loadWorkbook(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", fileName, err)
+			}
+			lessonFiles = append(lessonFiles, sheets...)
+		case ext == ".csv":
+			levelName, lessonNum, ok := parseLessonName(strings.TrimSuffix(fileName, filepath.Ext(fileName)))
+			if !ok {
+				log.Printf("   ! Предупреждение: %s не подходит под шаблон <уровень>_lesson_<номер>.csv, файл не загружен", fileName)
+				continue
+			}
+			lessonFiles = append(lessonFiles, namedLesson(path, levelName, lessonNum, csvSource(path)))
 		}
 	}
 
 	// Сортируем! Сначала по имени уровня (A0, A1), потом по номеру урока (1, 2, 10)
-	sort.Slice(lessonFiles, func(i, j int) bool {
-		if lessonFiles[i].LevelName != lessonFiles[j].LevelName {
-			return lessonFiles[i].LevelName < lessonFiles[j].LevelName
-		}
-		return lessonFiles[i].LessonNum < lessonFiles[j].LessonNum
-	})
-
+	sortLessonFiles(lessonFiles)
 	return lessonFiles, nil
 }
 
-// getOrInsertLevel находит ID уровня или создает новый
-func getOrInsertLevel(tx *sql.Tx, title string) (int, error) {
+// getOrInsertLevel находит ID уровня курса или создает новый
+func getOrInsertLevel(tx *sql.Tx, courseID int, title string) (int, error) {
 	var id int
-	err := tx.QueryRow("SELECT id FROM levels WHERE title = $1", title).Scan(&id)
+	err := tx.QueryRow("SELECT id FROM levels WHERE course_id = $1 AND title = $2", courseID, title).Scan(&id)
 	if err == sql.ErrNoRows {
 		// Не найден, создаем
-		err = tx.QueryRow("INSERT INTO levels (title) VALUES ($1) RETURNING id", title).Scan(&id)
+		err = tx.QueryRow("INSERT INTO levels (course_id, title) VALUES ($1, $2) RETURNING id", courseID, title).Scan(&id)
 		if err != nil {
 			return 0, err
 		}
 		log.Printf(" -> Создан новый уровень: '%s' (ID: %d)", title, id)
+		report.Levels.Added = append(report.Levels.Added, title)
 	} else if err != nil {
 		// Другая ошибка
 		return 0, err
@@ -180,18 +344,19 @@ func getOrInsertLevel(tx *sql.Tx, title string) (int, error) {
 	return id, nil
 }
 
-// getOrInsertLesson находит ID урока или создает новый
-func getOrInsertLesson(tx *sql.Tx, levelID int, lessonNum int, title string) (int, error) {
+// getOrInsertLesson находит ID урока или создает новый со статусом status и датой публикации publishAt
+func getOrInsertLesson(tx *sql.Tx, levelID int, lessonNum int, title string, status string, publishAt *time.Time) (int, error) {
 	var id int
 	err := tx.QueryRow("SELECT id FROM lessons WHERE level_id = $1 AND lesson_number = $2", levelID, lessonNum).Scan(&id)
 	if err == sql.ErrNoRows {
-		// Не найден, создаем
-		err = tx.QueryRow("INSERT INTO lessons (level_id, lesson_number, title) VALUES ($1, $2, $3) RETURNING id",
-			levelID, lessonNum, title).Scan(&id)
+		// Не найден, создаем (статус существующих уроков не трогаем)
+		err = tx.QueryRow("INSERT INTO lessons (level_id, lesson_number, title, status, publish_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+			levelID, lessonNum, title, status, publishAt).Scan(&id)
 		if err != nil {
 			return 0, err
 		}
 		log.Printf("   -> Создан новый урок: '%s' (ID: %d)", title, id)
+		report.Lessons.Added = append(report.Lessons.Added, report.current)
 	} else if err != nil {
 		// Другая ошибка
 		return 0, err
@@ -200,42 +365,166 @@ func getOrInsertLesson(tx *sql.Tx, levelID int, lessonNum int, title string) (in
 	return id, nil
 }
 
-// loadSentences читает CSV и вставляет предложения
-// loadSentences читает CSV и вставляет/обновляет предложения
-func loadSentences(tx *sql.Tx, lessonID int, csvPath string) (int, error) {
-	file, err := os.Open(csvPath)
+// updateMetadata переносит из манифеста порядок и описание уровня, название, описание,
+// грамматические темы урока и, если они указаны, статус и дату публикации
+func updateMetadata(tx *sql.Tx, levelID, lessonID int, lf lessonFile) error {
+	var oldOrder int
+	var oldLevelDescription string
+	err := tx.QueryRow("SELECT sort_order, COALESCE(description, '') FROM levels WHERE id = $1", levelID).Scan(&oldOrder, &oldLevelDescription)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	if diffs := diffFields(
+		fieldDiff{"sort_order", oldOrder, lf.LevelOrder},
+		fieldDiff{"description", oldLevelDescription, lf.LevelDescription},
+	); diffs != nil {
+		// уровень общий для многих уроков - в отчет попадает один раз
+		if !levelReported(lf.LevelName) {
+			report.Levels.Changed = append(report.Levels.Changed, levelChange{Level: lf.LevelName, Fields: diffs})
+		}
+		if _, err := tx.Exec("UPDATE levels SET sort_order = $1, description = NULLIF($2, '') WHERE id = $3",
+			lf.LevelOrder, lf.LevelDescription, levelID); err != nil {
+			return err
+		}
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	order := 1 // Порядковый номер предложения в уроке
-	count := 0
-	
-	// Этот запрос ОБНОВИТ существующие строки, сохранив их ID
-	// Он также обнуляет audio_path, чтобы пометить их для новой озвучки
-	sqlStatement := `
-		INSERT INTO sentences (lesson_id, order_number, prompt_ru, answer_en, transcription, audio_path) 
-		VALUES ($1, $2, $3, $4, $5, NULL) 
-		ON CONFLICT (lesson_id, order_number) 
-		DO UPDATE SET
-			prompt_ru = EXCLUDED.prompt_ru,
-			answer_en = EXCLUDED.answer_en,
-			transcription = EXCLUDED.transcription,
-			audio_path = NULL;` // Обнуляем аудио, т.к. текст 100% изменился
-
-	stmt, err := tx.PrepareContext(context.Background(), sqlStatement)
+	var oldTitle, oldDescription, oldStatus string
+	var oldTopicsJSON []byte
+	var oldPublishAt sql.NullTime
+	err = tx.QueryRow("SELECT COALESCE(title, ''), COALESCE(description, ''), grammar_topics, status, publish_at FROM lessons WHERE id = $1", lessonID).
+		Scan(&oldTitle, &oldDescription, &oldTopicsJSON, &oldStatus, &oldPublishAt)
+	if err != nil {
+		return err
+	}
+	oldTopics := []string{}
+	json.Unmarshal(oldTopicsJSON, &oldTopics)
+	fields := []fieldDiff{
+		{"title", oldTitle, lf.LessonTitle},
+		{"description", oldDescription, lf.Description},
+		{"grammar_topics", oldTopics, lf.GrammarTopics},
+	}
+	// Без status в манифесте публикацией управляют из админки и API
+	status, publishAt := oldStatus, nullTimePtr(oldPublishAt)
+	if lf.Status != "" {
+		status, publishAt = lf.Status, lf.PublishAt
+		fields = append(fields,
+			fieldDiff{"status", oldStatus, status},
+			fieldDiff{"publish_at", formatPublishAt(nullTimePtr(oldPublishAt)), formatPublishAt(publishAt)})
+	}
+	diffs := diffFields(fields...)
+	if diffs == nil {
+		return nil
+	}
+	if !lessonAdded(lf) {
+		report.Lessons.Changed = append(report.Lessons.Changed, lessonChange{lessonRef: report.current, Fields: diffs})
+	}
+	topics, err := json.Marshal(lf.GrammarTopics)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE lessons SET title = $1, description = NULLIF($2, ''), grammar_topics = $3::jsonb, status = $4, publish_at = $5 WHERE id = $6",
+		lf.LessonTitle, lf.Description, string(topics), status, publishAt, lessonID)
+	return err
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// formatPublishAt - publish_at для сравнения и отчета; пусто - публикация сразу
+func formatPublishAt(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func levelReported(level string) bool {
+	for _, lv := range report.Levels.Changed {
+		if lv.Level == level {
+			return true
+		}
+	}
+	for _, lv := range report.Levels.Added {
+		if lv == level {
+			return true
+		}
+	}
+	return false
+}
+
+// lessonAdded - урок создан в этом запуске (его метаданные не считаются изменением)
+func lessonAdded(lf lessonFile) bool {
+	for _, l := range report.Lessons.Added {
+		if l.Level == lf.LevelName && l.Lesson == lf.LessonNum {
+			return true
+		}
+	}
+	return false
+}
+
+// sentenceRow - строка источника. Наш CSV: sentence_id(0), prompt(1), answer(2), transcription(3)
+type sentenceRow struct {
+	ExternalID    string // sentence_id: постоянный ключ предложения в уроке
+	Prompt        string
+	Answer        string
+	Transcription string
+}
+
+// loadSentences читает строки урока и вставляет/обновляет предложения. Урок, который
+// не менялся с прошлой загрузки (checksum.go), пропускается, если не задан force
+func loadSentences(tx *sql.Tx, lessonID int, src sentenceSource, sourceRef string, policy string, force bool) (int, error) {
+	records, err := src.Records()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := sentenceRows(records)
 	if err != nil {
 		return 0, err
 	}
-	defer stmt.Close()
+	checksum := sourceChecksum(records)
+	if !force {
+		unchanged, err := lessonUnchanged(tx, lessonID, checksum)
+		if err != nil {
+			return 0, err
+		}
+		if unchanged {
+			log.Printf("   = Без изменений с прошлой загрузки, пропуск")
+			report.Unchanged++
+			return len(rows), nil
+		}
+	}
+	if err := syncSentences(tx, lessonID, rows, sourceRef, policy); err != nil {
+		return 0, err
+	}
+	return len(rows), saveLessonSource(tx, lessonID, sourceRef, checksum)
+}
+
+// csvRecord - строка CSV после заголовка с номером строки в файле (для сообщений)
+type csvRecord struct {
+	Line   int
+	Fields []string
+}
+
+// readCSVRecords читает CSV целиком, пропуская заголовок
+func readCSVRecords(csvPath string) ([]csvRecord, error) {
+	file, err := os.Open(csvPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
 
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // короткие строки разбирает линтер (lint.go)
+	var records []csvRecord
 
 	// Пропускаем заголовок (headers)
 	_, err = reader.Read()
-	if err == io.EOF { 
-		return 0, nil // Файл пустой (только заголовок)
+	if err == io.EOF {
+		return nil, nil // Файл пустой (только заголовок)
 	}
 
 	for {
@@ -244,38 +533,198 @@ func loadSentences(tx *sql.Tx, lessonID int, csvPath string) (int, error) {
 			break // Файл закончился
 		}
 		if err != nil {
-			return count, err
+			return nil, err
 		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, csvRecord{Line: line, Fields: record})
+	}
+	return records, nil
+}
 
-		// === ВАЖНОЕ ИСПРАВЛЕНИЕ ЗДЕСЬ ===
-		// Наш CSV: sentence_id(0), prompt_ru(1), answer_en(2), transcription(3)
-		
+// sentenceRows разбирает строки урока. Некорректные строки к этому моменту уже отсеял линтер;
+// здесь остаются проверки, без которых загрузка просто невозможна
+func sentenceRows(records []csvRecord) ([]sentenceRow, error) {
+	var rows []sentenceRow
+	seen := map[string]int{} // sentence_id -> строка файла
+	for _, rec := range records {
+		record := rec.Fields
 		if len(record) < 3 { // Нам нужны как минимум колонки 0, 1, 2
-			log.Printf("   ! Пропуск строки (мало столбцов): %v", record)
-			continue
+			return nil, fmt.Errorf("строка %d: мало столбцов", rec.Line)
 		}
 
-		promptRU := record[1] // <-- Берем ИНДЕКС 1
-		answerEN := record[2] // <-- Берем ИНДЕКС 2
-		
-		transcription := ""
+		row := sentenceRow{ExternalID: strings.TrimSpace(record[0]), Prompt: record[1], Answer: record[2]}
 		if len(record) > 3 {
-			transcription = strings.TrimSpace(record[3]) // <-- Берем ИНДЕКС 3
+			row.Transcription = strings.TrimSpace(record[3])
+		}
+		if row.ExternalID == "" {
+			return nil, fmt.Errorf("строка %d: пустой sentence_id", rec.Line)
+		}
+		if prev, ok := seen[row.ExternalID]; ok {
+			return nil, fmt.Errorf("строка %d: sentence_id %q уже встречался в строке %d", rec.Line, row.ExternalID, prev)
 		}
-		// ================================
+		seen[row.ExternalID] = rec.Line
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
 
-		// Вставляем или Обновляем в БД
-		_, err = stmt.ExecContext(context.Background(), lessonID, order, promptRU, answerEN, transcription)
-		if err != nil {
-			return count, err
+// storedSentence - предложение урока, как оно лежит в БД
+type storedSentence struct {
+	ID            int
+	Order         int
+	ExternalID    string
+	Prompt        string
+	Answer        string
+	Transcription string
+	Hash          string
+	Archived      bool
+}
+
+// syncSentences приводит предложения урока к строкам источника. Предложение определяется
+// по sentence_id (sentences.external_id), а не по месту в файле: вставка строки в середину
+// меняет только order_number следующих, и прогресс учеников остается при своих предложениях.
+// Предложения, которых больше нет в источнике, уходят в архив (archived_at) вместе с прогрессом;
+// если sentence_id вернется, предложение восстановится.
+func syncSentences(tx *sql.Tx, lessonID int, rows []sentenceRow, sourceRef, policy string) error {
+	dbRows, err := tx.Query(`SELECT id, order_number, COALESCE(external_id, ''), prompt, answer, COALESCE(transcription, ''),
+			COALESCE(content_hash, ''), archived_at IS NOT NULL
+		FROM sentences WHERE lesson_id = $1 ORDER BY order_number FOR UPDATE`, lessonID)
+	if err != nil {
+		return err
+	}
+	var stored []*storedSentence
+	byExternalID := map[string]*storedSentence{}
+	for dbRows.Next() {
+		s := &storedSentence{}
+		if err := dbRows.Scan(&s.ID, &s.Order, &s.ExternalID, &s.Prompt, &s.Answer, &s.Transcription, &s.Hash, &s.Archived); err != nil {
+			dbRows.Close()
+			return err
 		}
-		
-		order++
-		count++
+		stored = append(stored, s)
+		if s.ExternalID != "" {
+			byExternalID[s.ExternalID] = s
+		}
+	}
+	dbRows.Close()
+	if err := dbRows.Err(); err != nil {
+		return err
 	}
-	return count, nil
+
+	target := map[int]int{} // ID предложения -> новый order_number
+	for i, row := range rows {
+		if s, ok := byExternalID[row.ExternalID]; ok {
+			target[s.ID] = i + 1
+		}
+	}
+
+	// 1. Освобождаем номера: UNIQUE (lesson_id, order_number) проверяется на каждой строке,
+	// поэтому те, кто переезжает, сначала получают заведомо свободный -id, а уходящие в архив -
+	// номер из архивного диапазона
+	for _, s := range stored {
+		if s.Archived || target[s.ID] == s.Order {
+			continue
+		}
+		if target[s.ID] != 0 {
+			if _, err := tx.Exec("UPDATE sentences SET order_number = -id WHERE id = $1", s.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := tx.Exec("UPDATE sentences SET order_number = -(id + $2), archived_at = NOW() WHERE id = $1", s.ID, archivedOrderOffset); err != nil {
+			return err
+		}
+		report.Sentences.Removed = append(report.Sentences.Removed, report.sentence(s.ID, s.Order, s.ExternalID, s.Prompt, s.Answer))
+		log.Printf("   - Предложение #%d (sentence_id %q, ID: %d) нет в источнике, перенесено в архив", s.Order, s.ExternalID, s.ID)
+	}
+
+	// 2. Строки источника по порядку
+	for i, row := range rows {
+		order := i + 1
+		s, ok := byExternalID[row.ExternalID]
+		if !ok {
+			if err := insertSentence(tx, lessonID, order, row, sourceRef); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := updateSentence(tx, s, order, row, sourceRef, policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertSentence(tx *sql.Tx, lessonID, order int, row sentenceRow, sourceRef string) error {
+	rev := content.Revision{Prompt: row.Prompt, Answer: row.Answer, Transcription: row.Transcription,
+		Source: content.SourceDataLoader, SourceRef: sourceRef}
+	err := tx.QueryRow(`INSERT INTO sentences (lesson_id, order_number, external_id, prompt, answer, transcription, audio_path)
+		VALUES ($1, $2, $3, $4, $5, $6, NULL) RETURNING id`,
+		lessonID, order, row.ExternalID, row.Prompt, row.Answer, row.Transcription).Scan(&rev.SentenceID)
+	if err != nil {
+		return err
+	}
+	report.Sentences.Added = append(report.Sentences.Added, report.sentence(rev.SentenceID, order, row.ExternalID, row.Prompt, row.Answer))
+	_, err = content.RecordRevision(tx, rev)
+	return err
 }
 
+// updateSentence переносит предложение на место order и, если нужно, возвращает из архива.
+// Неизмененный текст (по content_hash) не трогает; при изменении сохраняет версию
+// в sentence_revisions, обнуляет audio_path, если изменился answer (озвучивается только он),
+// и, если ответ изменился по существу, применяет к прогрессу учеников policy.
+func updateSentence(tx *sql.Tx, s *storedSentence, order int, row sentenceRow, sourceRef, policy string) error {
+	change := sentenceChange{sentenceRef: report.sentence(s.ID, order, row.ExternalID, row.Prompt, row.Answer)}
+	if s.Archived {
+		change.Fields = append(change.Fields, fieldDiff{"archived", true, false})
+	} else if s.Order != order {
+		change.Fields = append(change.Fields, fieldDiff{"order_number", s.Order, order})
+	}
+	if s.Archived || s.Order != order {
+		if _, err := tx.Exec("UPDATE sentences SET order_number = $1, archived_at = NULL WHERE id = $2", order, s.ID); err != nil {
+			return err
+		}
+	}
+
+	if s.Hash == content.Hash(row.Prompt, row.Answer, row.Transcription) {
+		if change.Fields != nil {
+			report.Sentences.Changed = append(report.Sentences.Changed, change)
+		}
+		return nil
+	}
+	change.Fields = append(change.Fields, diffFields(
+		fieldDiff{"prompt", s.Prompt, row.Prompt},
+		fieldDiff{"answer", s.Answer, row.Answer},
+		fieldDiff{"transcription", s.Transcription, row.Transcription},
+	)...)
+
+	// Обнуляем аудио, только если изменился озвучиваемый текст
+	_, err := tx.Exec(`UPDATE sentences SET prompt = $1, answer = $2, transcription = $3,
+		audio_path = CASE WHEN answer = $2 THEN audio_path END WHERE id = $4`,
+		row.Prompt, row.Answer, row.Transcription, s.ID)
+	if err != nil {
+		return err
+	}
+	rev := content.Revision{SentenceID: s.ID, Prompt: row.Prompt, Answer: row.Answer, Transcription: row.Transcription,
+		Source: content.SourceDataLoader, SourceRef: sourceRef}
+	if content.MaterialChange(s.Answer, row.Answer) {
+		users, err := content.ProgressUsers(tx, s.ID, policy)
+		if err != nil {
+			return err
+		}
+		report.addUsers(users)
+		rev.Policy = policy
+		if rev.Affected, err = content.ApplyProgressPolicy(tx, s.ID, policy); err != nil {
+			return err
+		}
+		log.Printf("   ~ Ответ #%d изменен по существу: %q -> %q (прогресс: %s, затронуто записей: %d)",
+			order, s.Answer, row.Answer, policy, rev.Affected)
+		change.MaterialChange, change.ProgressPolicy, change.AffectedRows = true, policy, rev.Affected
+		report.Progress.AffectedRows += rev.Affected
+	}
+	report.Sentences.Changed = append(report.Sentences.Changed, change)
+	_, err = content.RecordRevision(tx, rev)
+	return err
+}
 
 
 
@@ -403,8 +852,8 @@ func loadSentences(tx *sql.Tx, lessonID int, csvPath string) (int, error) {
 // 			batch.Queue(sqlStatement,
 // 				lessonID,  // $1
 // 				orderNum,  // $2 (record[0])
-// 				record[1], // $3 (prompt_ru)
-// 				record[2], // $4 (answer_en)
+// 				record[1], // $3 (prompt)
+// 				record[2], // $4 (answer)
 // 				record[3], // $5 (transcription)
 // 				record[4], // $6 (audio_path)
 // 			)
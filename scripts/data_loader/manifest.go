@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Манифест курса (JSON): какие уровни есть, в каком порядке они идут, названия и описания уроков,
+// грамматические темы и откуда брать предложения - из CSV, с листа книги XLSX или прямо
+// из манифеста (sentences). Манифест, где у всех уроков есть sentences, - это JSON-курс:
+// самодостаточный файл без CSV. Пример - scripts/manifest.example.json.
+//
+// Без манифеста загрузчик работает по-старому: уровни и номера уроков берутся из имен файлов.
+type courseManifest struct {
+	Course manifestCourse  `json:"course"` // языковая пара (course.go)
+	Levels []manifestLevel `json:"levels"`
+}
+
+type manifestLevel struct {
+	Title       string           `json:"title"`           // "A0", "C1"
+	Order       *int             `json:"order,omitempty"` // порядок показа; нет - по положению в манифесте
+	Description string           `json:"description,omitempty"`
+	Lessons     []manifestLesson `json:"lessons"`
+}
+
+type manifestLesson struct {
+	Number        int      `json:"number"`
+	Title         string   `json:"title,omitempty"` // пусто - "A0 - Урок 1"
+	Description   string   `json:"description,omitempty"`
+	GrammarTopics []string `json:"grammar_topics,omitempty"`
+	File          string   `json:"file,omitempty"`  // путь к CSV или XLSX относительно манифеста
+	Sheet         string   `json:"sheet,omitempty"` // лист книги XLSX
+
+	// Публикация: пусто - новый урок создается по флагу -publish, у существующего статус не меняется.
+	// Выгрузка (export.go) пишет оба поля, чтобы загрузка выгруженного вернула их как были
+	Status    string     `json:"status,omitempty"`     // draft, review, published, archived
+	PublishAt *time.Time `json:"publish_at,omitempty"` // RFC 3339; только вместе со status
+
+	// JSON-курс: предложения прямо в манифесте вместо file. Указатель - чтобы отличать
+	// пустой урок ("sentences": []) от урока, где предложения забыли указать
+	Sentences *[]manifestSentence `json:"sentences,omitempty"`
+}
+
+type manifestSentence struct {
+	SentenceID    jsonID `json:"sentence_id"`
+	Prompt        string `json:"prompt"`
+	Answer        string `json:"answer"`
+	Transcription string `json:"transcription,omitempty"`
+
+	// Имена полей из JSON-курсов, написанных до появления курсов (только ru-en)
+	LegacyPrompt string `json:"prompt_ru,omitempty"`
+	LegacyAnswer string `json:"answer_en,omitempty"`
+}
+
+// jsonID - sentence_id в JSON можно писать и строкой, и числом: "12" и 12 - одно и то же
+type jsonID string
+
+func (id *jsonID) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*id = jsonID(n.String())
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("sentence_id должен быть строкой или числом")
+	}
+	*id = jsonID(s)
+	return nil
+}
+
+// loadManifest читает манифест и возвращает уроки в порядке уровней и номеров.
+// Файл, указанный в манифесте, но отсутствующий на диске - ошибка; CSV рядом с манифестом,
+// которых в нем нет, - предупреждение.
+func loadManifest(path string) (manifestCourse, []lessonFile, error) {
+	var m courseManifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m.Course, nil, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m.Course, nil, fmt.Errorf("манифест %s: %v", path, err)
+	}
+	if len(m.Levels) == 0 {
+		return m.Course, nil, fmt.Errorf("манифест %s: нет ни одного уровня", path)
+	}
+	lessonFiles, err := manifestLessons(path, m)
+	return m.Course, lessonFiles, err
+}
+
+// manifestLessons - уроки манифеста в порядке уровней и номеров
+func manifestLessons(path string, m courseManifest) ([]lessonFile, error) {
+	dir := filepath.Dir(path)
+	var lessonFiles []lessonFile
+	levels := map[string]bool{}
+	used := map[string]bool{}
+	for i, lv := range m.Levels {
+		lv.Title = strings.TrimSpace(lv.Title)
+		if lv.Title == "" || len(lv.Title) > 50 {
+			return nil, fmt.Errorf("манифест: уровень #%d: title обязателен (до 50 символов)", i+1)
+		}
+		if levels[lv.Title] {
+			return nil, fmt.Errorf("манифест: уровень %s указан дважды", lv.Title)
+		}
+		levels[lv.Title] = true
+		order := i + 1
+		if lv.Order != nil {
+			order = *lv.Order
+		}
+
+		numbers := map[int]bool{}
+		for _, ls := range lv.Lessons {
+			if ls.Number <= 0 {
+				return nil, fmt.Errorf("манифест: уровень %s: номер урока должен быть положительным", lv.Title)
+			}
+			if numbers[ls.Number] {
+				return nil, fmt.Errorf("манифест: уровень %s: урок %d указан дважды", lv.Title, ls.Number)
+			}
+			numbers[ls.Number] = true
+			if err := validateLessonStatus(ls); err != nil {
+				return nil, fmt.Errorf("манифест: %s, урок %d: %v", lv.Title, ls.Number, err)
+			}
+			src, ref, err := manifestSource(path, dir, lv.Title, ls)
+			if err != nil {
+				return nil, fmt.Errorf("манифест: %s, урок %d: %v", lv.Title, ls.Number, err)
+			}
+			if ls.File != "" {
+				used[manifestFilePath(dir, ls.File)] = true
+			}
+
+			title := strings.TrimSpace(ls.Title)
+			if title == "" {
+				title = fmt.Sprintf("%s - Урок %d", lv.Title, ls.Number)
+			}
+			topics := ls.GrammarTopics
+			if topics == nil {
+				topics = []string{}
+			}
+			lessonFiles = append(lessonFiles, lessonFile{
+				Path:             ref,
+				Source:           src,
+				LevelName:        lv.Title,
+				LessonNum:        ls.Number,
+				LessonTitle:      title,
+				FromManifest:     true,
+				LevelOrder:       order,
+				LevelDescription: lv.Description,
+				Description:      ls.Description,
+				GrammarTopics:    topics,
+				Status:           ls.Status,
+				PublishAt:        ls.PublishAt,
+			})
+		}
+	}
+
+	sort.SliceStable(lessonFiles, func(i, j int) bool {
+		if lessonFiles[i].LevelOrder != lessonFiles[j].LevelOrder {
+			return lessonFiles[i].LevelOrder < lessonFiles[j].LevelOrder
+		}
+		return lessonFiles[i].LessonNum < lessonFiles[j].LessonNum
+	})
+
+	// CSV рядом с манифестом, которые в нем не упомянуты, скорее всего забыли добавить
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !isLessonFile(name) {
+			continue
+		}
+		if !used[filepath.Clean(filepath.Join(dir, name))] {
+			log.Printf("   ! Предупреждение: %s нет в манифесте, файл не загружен", name)
+		}
+	}
+	return lessonFiles, nil
+}
+
+// lessonStatuses - допустимые lessons.status (CHECK в scripts/init.sql)
+var lessonStatuses = map[string]bool{"draft": true, "review": true, "published": true, "archived": true}
+
+func validateLessonStatus(ls manifestLesson) error {
+	if ls.Status == "" {
+		if ls.PublishAt != nil {
+			return fmt.Errorf("publish_at указан без status")
+		}
+		return nil
+	}
+	if !lessonStatuses[ls.Status] {
+		return fmt.Errorf("неизвестный status %q (draft, review, published, archived)", ls.Status)
+	}
+	return nil
+}
+
+// manifestFilePath - путь из манифеста; относительный считается от каталога манифеста
+func manifestFilePath(dir, file string) string {
+	if filepath.IsAbs(file) {
+		return filepath.Clean(file)
+	}
+	return filepath.Join(dir, file)
+}
+
+// manifestSource - откуда брать предложения урока: file (CSV или лист XLSX) или sentences
+func manifestSource(manifestPath, dir, level string, ls manifestLesson) (sentenceSource, string, error) {
+	switch {
+	case ls.File != "" && ls.Sentences != nil:
+		return nil, "", fmt.Errorf("нужно что-то одно: file или sentences")
+	case ls.Sentences != nil:
+		// Номер строки - порядковый номер предложения в уроке, для сообщений линтера
+		records := make(inlineSource, len(*ls.Sentences))
+		for i, s := range *ls.Sentences {
+			if s.Prompt == "" {
+				s.Prompt = s.LegacyPrompt
+			}
+			if s.Answer == "" {
+				s.Answer = s.LegacyAnswer
+			}
+			records[i] = csvRecord{Line: i + 1, Fields: []string{string(s.SentenceID), s.Prompt, s.Answer, s.Transcription}}
+		}
+		return records, fmt.Sprintf("%s#%s_lesson_%d", manifestPath, level, ls.Number), nil
+	case ls.File == "":
+		return nil, "", fmt.Errorf("не указан file или sentences")
+	}
+	filePath := manifestFilePath(dir, ls.File)
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, "", err
+	}
+	return fileSource(filePath, ls.Sheet)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Отчет о загрузке: что добавлено, изменено и удалено по сравнению с БД. В режиме -dry-run
+// загрузка выполняется в транзакции, которая потом откатывается, поэтому отчет ровно тот,
+// что получился бы при настоящем запуске. -json печатает его в stdout для CI
+// (лог загрузчика идет в stderr).
+type loadReport struct {
+	DryRun    bool            `json:"dry_run"`
+	Course    courseDiff      `json:"course"`
+	Levels    levelsDiff      `json:"levels"`
+	Lessons   lessonsDiff     `json:"lessons"`
+	Sentences sentencesDiff   `json:"sentences"`
+	Progress  progressSummary `json:"progress"`
+	Unchanged int             `json:"unchanged_lessons"` // пропущены: не менялись с прошлой загрузки
+	Lint      []lintIssue     `json:"lint"`              // замечания проверки CSV (lint.go) и staging (staging.go)
+
+	ContentVersion int64 `json:"content_version,omitempty"` // версия контента после загрузки (не в dry-run)
+
+	current lessonRef       // урок, который сейчас загружается
+	seen    map[string]bool // уроки из источника, "A0/1"
+	users   map[int]bool    // ученики, чей прогресс затронут
+}
+
+// courseDiff - курс, в который шла загрузка (course.go)
+type courseDiff struct {
+	Code   string      `json:"code"`
+	Added  bool        `json:"added"`
+	Fields []fieldDiff `json:"fields,omitempty"`
+}
+
+type levelsDiff struct {
+	Added   []string      `json:"added"`
+	Changed []levelChange `json:"changed"`
+}
+
+type levelChange struct {
+	Level  string      `json:"level"`
+	Fields []fieldDiff `json:"fields"`
+}
+
+type lessonsDiff struct {
+	Added   []lessonRef    `json:"added"`
+	Changed []lessonChange `json:"changed"`
+	Removed []lessonRef    `json:"removed"` // есть в БД, нет в источнике (загрузчик их не трогает)
+}
+
+type lessonRef struct {
+	Level  string `json:"level"`
+	Lesson int    `json:"lesson"`
+	Title  string `json:"title"`
+	File   string `json:"file,omitempty"`
+}
+
+type lessonChange struct {
+	lessonRef
+	Fields []fieldDiff `json:"fields"`
+}
+
+type sentencesDiff struct {
+	Added   []sentenceRef    `json:"added"`
+	Changed []sentenceChange `json:"changed"`
+	Removed []sentenceRef    `json:"removed"` // нет в источнике, перенесены в архив
+}
+
+type sentenceRef struct {
+	Level      string `json:"level"`
+	Lesson     int    `json:"lesson"`
+	Order      int    `json:"order"`
+	ExternalID string `json:"sentence_id"`
+	ID         int    `json:"id"` // у новых в dry-run - ID из откаченной транзакции
+	Prompt     string `json:"prompt"`
+	Answer     string `json:"answer"`
+}
+
+type sentenceChange struct {
+	sentenceRef
+	Fields         []fieldDiff `json:"fields"`
+	MaterialChange bool        `json:"material_change"` // ответ изменился по существу
+	ProgressPolicy string      `json:"progress_policy,omitempty"`
+	AffectedRows   int64       `json:"affected_progress"`
+}
+
+type fieldDiff struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+type progressSummary struct {
+	AffectedRows  int64 `json:"affected_progress"` // записи user_progress, к которым применена политика
+	AffectedUsers int   `json:"affected_users"`
+}
+
+func newLoadReport(dryRun bool) *loadReport {
+	return &loadReport{
+		DryRun:    dryRun,
+		Levels:    levelsDiff{Added: []string{}, Changed: []levelChange{}},
+		Lessons:   lessonsDiff{Added: []lessonRef{}, Changed: []lessonChange{}, Removed: []lessonRef{}},
+		Sentences: sentencesDiff{Added: []sentenceRef{}, Changed: []sentenceChange{}, Removed: []sentenceRef{}},
+		Lint:      []lintIssue{},
+		seen:      map[string]bool{},
+		users:     map[int]bool{},
+	}
+}
+
+// report - отчет текущего запуска (загрузчик - одноразовая команда)
+var report = newLoadReport(false)
+
+func lessonKey(level string, lesson int) string {
+	return fmt.Sprintf("%s/%d", level, lesson)
+}
+
+func (rep *loadReport) startLesson(lf lessonFile) {
+	rep.current = lessonRef{Level: lf.LevelName, Lesson: lf.LessonNum, Title: lf.LessonTitle, File: lf.Path}
+	rep.seen[lessonKey(lf.LevelName, lf.LessonNum)] = true
+}
+
+func (rep *loadReport) sentence(id, order int, externalID, prompt, answer string) sentenceRef {
+	return sentenceRef{Level: rep.current.Level, Lesson: rep.current.Lesson, Order: order, ExternalID: externalID, ID: id,
+		Prompt: prompt, Answer: answer}
+}
+
+// addUsers учитывает учеников, чей прогресс затронула политика
+func (rep *loadReport) addUsers(ids []int) {
+	for _, id := range ids {
+		rep.users[id] = true
+	}
+	rep.Progress.AffectedUsers = len(rep.users)
+}
+
+// diffFields сравнивает пары (поле, старое, новое) и возвращает только изменившиеся
+func diffFields(pairs ...fieldDiff) []fieldDiff {
+	var diffs []fieldDiff
+	for _, p := range pairs {
+		if fmt.Sprint(p.Old) != fmt.Sprint(p.New) {
+			diffs = append(diffs, p)
+		}
+	}
+	return diffs
+}
+
+func (rep *loadReport) empty() bool {
+	return !rep.Course.Added && len(rep.Course.Fields)+len(rep.Levels.Added)+len(rep.Levels.Changed)+
+		len(rep.Lessons.Added)+len(rep.Lessons.Changed)+len(rep.Lessons.Removed)+
+		len(rep.Sentences.Added)+len(rep.Sentences.Changed)+len(rep.Sentences.Removed) == 0
+}
+
+func (rep *loadReport) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// writeText печатает отчет для человека
+func (rep *loadReport) writeText(w io.Writer) {
+	title := "Изменения"
+	if rep.DryRun {
+		title = "Изменения (dry-run, в БД ничего не записано)"
+	}
+	fmt.Fprintf(w, "=== %s ===\n", title)
+	if rep.Unchanged > 0 {
+		fmt.Fprintf(w, "Уроков без изменений (пропущены): %d\n", rep.Unchanged)
+	}
+	if rep.empty() {
+		fmt.Fprintln(w, "Нет изменений.")
+		return
+	}
+	if rep.Course.Added {
+		fmt.Fprintf(w, "+ курс %s\n", rep.Course.Code)
+	} else if rep.Course.Fields != nil {
+		fmt.Fprintf(w, "~ курс %s\n", rep.Course.Code)
+		writeFields(w, rep.Course.Fields)
+	}
+	for _, lv := range rep.Levels.Added {
+		fmt.Fprintf(w, "+ уровень %s\n", lv)
+	}
+	for _, lv := range rep.Levels.Changed {
+		fmt.Fprintf(w, "~ уровень %s\n", lv.Level)
+		writeFields(w, lv.Fields)
+	}
+	for _, l := range rep.Lessons.Added {
+		fmt.Fprintf(w, "+ урок %s %q\n", lessonKey(l.Level, l.Lesson), l.Title)
+	}
+	for _, l := range rep.Lessons.Changed {
+		fmt.Fprintf(w, "~ урок %s\n", lessonKey(l.Level, l.Lesson))
+		writeFields(w, l.Fields)
+	}
+	for _, l := range rep.Lessons.Removed {
+		fmt.Fprintf(w, "- урок %s %q (нет в источнике)\n", lessonKey(l.Level, l.Lesson), l.Title)
+	}
+	for _, s := range rep.Sentences.Added {
+		fmt.Fprintf(w, "+ %s #%d [%s]: %s -> %s\n", lessonKey(s.Level, s.Lesson), s.Order, s.ExternalID, s.Prompt, s.Answer)
+	}
+	for _, s := range rep.Sentences.Changed {
+		mark := ""
+		if s.MaterialChange {
+			mark = fmt.Sprintf(" [ответ изменен по существу, прогресс: %s, записей: %d]", s.ProgressPolicy, s.AffectedRows)
+		}
+		fmt.Fprintf(w, "~ %s #%d [%s] (id %d)%s\n", lessonKey(s.Level, s.Lesson), s.Order, s.ExternalID, s.ID, mark)
+		writeFields(w, s.Fields)
+	}
+	for _, s := range rep.Sentences.Removed {
+		fmt.Fprintf(w, "- %s #%d [%s] (id %d): %s (в архив)\n", lessonKey(s.Level, s.Lesson), s.Order, s.ExternalID, s.ID, s.Prompt)
+	}
+	fmt.Fprintf(w, "Итого: уроков +%d ~%d -%d, предложений +%d ~%d -%d; прогресс: записей %d, учеников %d\n",
+		len(rep.Lessons.Added), len(rep.Lessons.Changed), len(rep.Lessons.Removed),
+		len(rep.Sentences.Added), len(rep.Sentences.Changed), len(rep.Sentences.Removed),
+		rep.Progress.AffectedRows, rep.Progress.AffectedUsers)
+}
+
+func writeFields(w io.Writer, fields []fieldDiff) {
+	for _, f := range fields {
+		fmt.Fprintf(w, "    %s: %s -> %s\n", f.Field, formatValue(f.Old), formatValue(f.New))
+	}
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []string:
+		quoted := make([]string, len(v))
+		for i, s := range v {
+			quoted[i] = fmt.Sprintf("%q", s)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	return fmt.Sprint(v)
+}
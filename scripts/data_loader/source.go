@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+)
+
+// Источники уроков. Загрузчику все равно, откуда пришли строки: файл CSV, лист книги XLSX
+// или предложения прямо в JSON-курсе (manifest.go) - дальше они идут одним путем:
+// проверка (lint.go), getOrInsertLevel/getOrInsertLesson, loadSentences.
+type sentenceSource interface {
+	// Records - строки урока без заголовка: sentence_id, prompt, answer, transcription
+	Records() ([]csvRecord, error)
+}
+
+// csvSource - файл CSV
+type csvSource string
+
+func (s csvSource) Records() ([]csvRecord, error) {
+	return readCSVRecords(string(s))
+}
+
+// xlsxSheetSource - лист книги XLSX, первая строка - заголовок, как в CSV
+type xlsxSheetSource struct {
+	path  string
+	sheet string
+}
+
+func (s xlsxSheetSource) Records() ([]csvRecord, error) {
+	book, err := openWorkbook(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if book.GetSheetIndex(s.sheet) == 0 {
+		return nil, fmt.Errorf("в книге нет листа %q", s.sheet)
+	}
+	var records []csvRecord
+	for i, row := range book.GetRows(s.sheet) {
+		if i == 0 {
+			continue // заголовок
+		}
+		// Excel отдает и пустые строки внизу листа (после удаления содержимого)
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		records = append(records, csvRecord{Line: i + 1, Fields: trimTrailingEmpty(row)})
+	}
+	return records, nil
+}
+
+// inlineSource - предложения, заданные прямо в JSON-курсе
+type inlineSource []csvRecord
+
+func (s inlineSource) Records() ([]csvRecord, error) {
+	return s, nil
+}
+
+// trimTrailingEmpty убирает пустые ячейки в конце строки: у листа ширина по самой длинной строке,
+// а короткую строку должен увидеть линтер
+func trimTrailingEmpty(row []string) []string {
+	end := len(row)
+	for end > 0 && row[end-1] == "" {
+		end--
+	}
+	return row[:end]
+}
+
+// Книга открывается один раз: с нее читают и линтер, и загрузка
+var workbooks = map[string]*excelize.File{}
+
+func openWorkbook(path string) (*excelize.File, error) {
+	if book, ok := workbooks[path]; ok {
+		return book, nil
+	}
+	book, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	workbooks[path] = book
+	return book, nil
+}
+
+// lessonNameRegexp - имя файла (без расширения) или листа урока: любой уровень вида A0..C2
+// ([A-C][0-9])_lesson_([0-9]+)
+var lessonNameRegexp = regexp.MustCompile(`^([A-C][0-9])_lesson_([0-9]+)$`)
+
+// parseLessonName разбирает "A0_lesson_1" на уровень и номер урока
+func parseLessonName(name string) (string, int, bool) {
+	matches := lessonNameRegexp.FindStringSubmatch(name)
+	// 0: A0_lesson_1
+	// 1: A0
+	// 2: 1
+	if len(matches) != 3 {
+		return "", 0, false
+	}
+	lessonNum, _ := strconv.Atoi(matches[2]) // Ошибка Atoi здесь маловероятна
+	return matches[1], lessonNum, true
+}
+
+// namedLesson - урок, у которого уровень и номер взяты из имени файла или листа
+func namedLesson(path, levelName string, lessonNum int, src sentenceSource) lessonFile {
+	return lessonFile{
+		Path:        path,
+		LevelName:   levelName,
+		LessonNum:   lessonNum,
+		LessonTitle: fmt.Sprintf("%s - Урок %d", levelName, lessonNum),
+		Source:      src,
+	}
+}
+
+// loadWorkbook - книга XLSX, один лист на урок; листы называются как CSV: A0_lesson_1
+func loadWorkbook(path string) ([]lessonFile, error) {
+	book, err := openWorkbook(path)
+	if err != nil {
+		return nil, err
+	}
+	sheets := book.GetSheetMap()
+	indexes := make([]int, 0, len(sheets))
+	for i := range sheets {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	var lessonFiles []lessonFile
+	for _, i := range indexes {
+		sheet := sheets[i]
+		levelName, lessonNum, ok := parseLessonName(sheet)
+		if !ok {
+			log.Printf("   ! Предупреждение: лист %q в %s не подходит под шаблон <уровень>_lesson_<номер>, лист не загружен",
+				sheet, filepath.Base(path))
+			continue
+		}
+		lessonFiles = append(lessonFiles, namedLesson(path+"#"+sheet, levelName, lessonNum, xlsxSheetSource{path: path, sheet: sheet}))
+	}
+	return lessonFiles, nil
+}
+
+// fileSource - источник для файла из манифеста: CSV или лист XLSX
+func fileSource(path, sheet string) (sentenceSource, string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		if sheet != "" {
+			return nil, "", fmt.Errorf("%s: sheet указывается только для XLSX", path)
+		}
+		return csvSource(path), path, nil
+	case ".xlsx":
+		if sheet == "" {
+			return nil, "", fmt.Errorf("%s: для XLSX нужно указать sheet", path)
+		}
+		return xlsxSheetSource{path: path, sheet: sheet}, path + "#" + sheet, nil
+	}
+	return nil, "", fmt.Errorf("%s: неизвестный формат (нужен .csv или .xlsx)", path)
+}
+
+// isLessonFile - файл, который может содержать уроки (для предупреждений о забытых файлах)
+func isLessonFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".xlsx":
+		return true
+	}
+	return false
+}
+
+// resolveLessonFiles находит уроки: по JSON-манифесту (или JSON-курсу), в книге XLSX
+// или в каталоге с CSV и XLSX. Курс известен только из манифеста, иначе он пустой
+func resolveLessonFiles(manifestPath, sourcePath string) (manifestCourse, []lessonFile, error) {
+	var course manifestCourse
+	if manifestPath == "" {
+		info, err := os.Stat(sourcePath)
+		if err != nil {
+			return course, nil, err
+		}
+		switch {
+		case info.IsDir():
+			if _, err := os.Stat(filepath.Join(sourcePath, "manifest.json")); err == nil {
+				manifestPath = filepath.Join(sourcePath, "manifest.json")
+			}
+		case strings.EqualFold(filepath.Ext(sourcePath), ".json"):
+			manifestPath = sourcePath
+		}
+	}
+
+	var lessonFiles []lessonFile
+	var err error
+	switch {
+	case manifestPath != "":
+		log.Printf("Чтение манифеста %s...", manifestPath)
+		course, lessonFiles, err = loadManifest(manifestPath)
+	case strings.EqualFold(filepath.Ext(sourcePath), ".xlsx"):
+		log.Printf("Чтение книги %s...", sourcePath)
+		lessonFiles, err = loadWorkbook(sourcePath)
+		if err == nil {
+			sortLessonFiles(lessonFiles)
+		}
+	default:
+		log.Println("Поиск и сортировка CSV и XLSX файлов...")
+		lessonFiles, err = findAndSortFiles(sourcePath)
+	}
+	if err != nil {
+		return course, nil, err
+	}
+	if err := checkDuplicateLessons(lessonFiles); err != nil {
+		return course, nil, err
+	}
+	log.Printf("Найдено %d уроков для обработки.", len(lessonFiles))
+	return course, lessonFiles, nil
+}
+
+// sortLessonFiles - сначала по имени уровня (A0, A1), потом по номеру урока (1, 2, 10)
+func sortLessonFiles(lessonFiles []lessonFile) {
+	sort.SliceStable(lessonFiles, func(i, j int) bool {
+		if lessonFiles[i].LevelName != lessonFiles[j].LevelName {
+			return lessonFiles[i].LevelName < lessonFiles[j].LevelName
+		}
+		return lessonFiles[i].LessonNum < lessonFiles[j].LessonNum
+	})
+}
+
+// checkDuplicateLessons - один урок из двух мест (CSV и лист книги) загрузить нельзя
+func checkDuplicateLessons(lessonFiles []lessonFile) error {
+	seen := map[string]string{}
+	for _, lf := range lessonFiles {
+		key := lessonKey(lf.LevelName, lf.LessonNum)
+		if prev, ok := seen[key]; ok {
+			return fmt.Errorf("урок %s есть и в %s, и в %s", key, prev, lf.Path)
+		}
+		seen[key] = lf.Path
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestParseLessonName(t *testing.T) {
+	tests := []struct {
+		name      string
+		wantLevel string
+		wantNum   int
+		wantOK    bool
+	}{
+		{"A0_lesson_1", "A0", 1, true},
+		{"B2_lesson_10", "B2", 10, true},
+		{"C1_lesson_007", "C1", 7, true},
+		{"D1_lesson_1", "", 0, false},
+		{"a0_lesson_1", "", 0, false},
+		{"A0_lesson_", "", 0, false},
+		{"A0_lesson_1_old", "", 0, false},
+		{"A0-lesson-1", "", 0, false},
+		{"", "", 0, false},
+	}
+	for _, tt := range tests {
+		level, num, ok := parseLessonName(tt.name)
+		if level != tt.wantLevel || num != tt.wantNum || ok != tt.wantOK {
+			t.Errorf("parseLessonName(%q) = %q, %d, %v, want %q, %d, %v",
+				tt.name, level, num, ok, tt.wantLevel, tt.wantNum, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Выкладка контента без простоя. Сервер в это время отдает уроки, поэтому загрузка идет так:
+//
+//  1. pg_try_advisory_lock: второй загрузчик не ждет и не перемешивает свои изменения
+//     с первым, а сразу завершается с ошибкой.
+//  2. Строки всех источников пишутся во временные таблицы staging_* на соединении загрузчика.
+//     Чтение и разбор файлов (XLSX, большие CSV) идет до транзакции над живыми таблицами.
+//  3. Проверка staging против текущего состояния БД (validateStaging): то, что линтер по одним
+//     файлам увидеть не может, например урок, который почти целиком уйдет в архив.
+//  4. Применение из staging одной короткой транзакцией с lock_timeout: ученики до COMMIT видят
+//     старый контент целиком, после - новый целиком, а content_version увеличивается на 1.
+//
+// Подменить таблицы целиком (RENAME) нельзя: на sentences ссылаются user_progress
+// и sentence_revisions, поэтому применение идет построчно той же сверкой, что и раньше.
+
+// loaderLockKey - ключ pg_advisory_lock загрузчика ("lingo" в ASCII)
+const loaderLockKey int64 = 0x6c696e676f
+
+// applyLockTimeout - сколько транзакция применения ждет блокировку строки, которую держит
+// правка из админки, прежде чем сдаться (ученики за это время не блокируются)
+const applyLockTimeout = "5s"
+
+// lockLoader берет advisory lock на время всей загрузки. Блокировка сессионная: снимается
+// unlockLoader или при закрытии соединения, в том числе если загрузчик упал
+func lockLoader(ctx context.Context, conn *sql.Conn) error {
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", loaderLockKey).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return errors.New("другая загрузка контента уже идет, попробуйте позже")
+	}
+	return nil
+}
+
+func unlockLoader(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", loaderLockKey)
+	return err
+}
+
+// stageLessons читает все источники и пишет их строки во временные таблицы
+func stageLessons(ctx context.Context, conn *sql.Conn, lessonFiles []lessonFile) error {
+	for _, stmt := range []string{
+		`CREATE TEMP TABLE staging_lessons (
+			ref TEXT PRIMARY KEY,
+			position INT NOT NULL,
+			level TEXT NOT NULL,
+			lesson_number INT NOT NULL
+		)`,
+		`CREATE TEMP TABLE staging_sentences (
+			ref TEXT NOT NULL REFERENCES staging_lessons(ref),
+			position INT NOT NULL,
+			line INT NOT NULL,
+			external_id TEXT NOT NULL,
+			fields JSONB NOT NULL,
+			PRIMARY KEY (ref, position)
+		)`,
+	} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	for i, lf := range lessonFiles {
+		records, err := lf.Source.Records()
+		if err != nil {
+			return fmt.Errorf("%s: %v", lf.Path, err)
+		}
+		if _, err := conn.ExecContext(ctx, "INSERT INTO staging_lessons (ref, position, level, lesson_number) VALUES ($1, $2, $3, $4)",
+			lf.Path, i+1, lf.LevelName, lf.LessonNum); err != nil {
+			return fmt.Errorf("%s: %v", lf.Path, err)
+		}
+		if len(records) == 0 {
+			continue
+		}
+		// Урок - одна вставка: строки передаются массивом JSON
+		staged := make([]stagedRecord, len(records))
+		for j, rec := range records {
+			staged[j] = stagedRecord{Line: rec.Line, Fields: rec.Fields}
+			if len(rec.Fields) > 0 {
+				staged[j].ExternalID = strings.TrimSpace(rec.Fields[0])
+			}
+		}
+		data, err := json.Marshal(staged)
+		if err != nil {
+			return err
+		}
+		_, err = conn.ExecContext(ctx, `INSERT INTO staging_sentences (ref, position, line, external_id, fields)
+			SELECT $1, r.position, (r.value->>'line')::int, r.value->>'external_id', r.value->'fields'
+			FROM jsonb_array_elements($2::jsonb) WITH ORDINALITY AS r(value, position)`, lf.Path, string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %v", lf.Path, err)
+		}
+	}
+	return nil
+}
+
+// stagedRecord - строка источника в staging_sentences
+type stagedRecord struct {
+	Line       int      `json:"line"`
+	ExternalID string   `json:"external_id"`
+	Fields     []string `json:"fields"`
+}
+
+// validateStaging сверяет staging с уроками курса в БД и возвращает замечания в формате линтера.
+// maxArchive - доля активных предложений урока, которую загрузке можно отправить в архив:
+// если больше, скорее всего перепутан файл или потерялся столбец sentence_id
+func validateStaging(ctx context.Context, conn *sql.Conn, courseCode string, maxArchive float64) ([]lintIssue, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT ref, level, lesson_number, active, archived FROM (
+			SELECT sl.ref, sl.position, sl.level, sl.lesson_number, COUNT(*) AS active,
+				COUNT(*) FILTER (WHERE NOT EXISTS (SELECT 1 FROM staging_sentences ss
+					WHERE ss.ref = sl.ref AND ss.external_id = s.external_id)) AS archived
+			FROM staging_lessons sl
+			JOIN courses c ON c.code = $2
+			JOIN levels lv ON lv.course_id = c.id AND lv.title = sl.level
+			JOIN lessons l ON l.level_id = lv.id AND l.lesson_number = sl.lesson_number
+			JOIN sentences s ON s.lesson_id = l.id AND s.archived_at IS NULL
+			GROUP BY sl.ref, sl.position, sl.level, sl.lesson_number
+		) t
+		WHERE archived > 0 AND archived > $1 * active
+		ORDER BY position`, maxArchive, courseCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	issues := []lintIssue{}
+	for rows.Next() {
+		var ref, level string
+		var lessonNum, active, archived int
+		if err := rows.Scan(&ref, &level, &lessonNum, &active, &archived); err != nil {
+			return nil, err
+		}
+		issues = append(issues, lintIssue{Severity: severityError, Code: "mass_archive", File: filepath.Base(ref),
+			Message: fmt.Sprintf("урок %s: в архив уйдут %d из %d предложений (допустимо не больше %.0f%%, см. -max-archive)",
+				lessonKey(level, lessonNum), archived, active, maxArchive*100)})
+	}
+	return issues, rows.Err()
+}
+
+// stagingSource - строки урока из staging_sentences: применяется ровно то, что проверено
+type stagingSource struct {
+	tx  *sql.Tx
+	ref string
+}
+
+func (s stagingSource) Records() ([]csvRecord, error) {
+	rows, err := s.tx.Query("SELECT line, fields FROM staging_sentences WHERE ref = $1 ORDER BY position", s.ref)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []csvRecord
+	for rows.Next() {
+		var rec csvRecord
+		var fields []byte
+		if err := rows.Scan(&rec.Line, &fields); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(fields, &rec.Fields); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// applyFromStaging переключает уроки на чтение из staging внутри транзакции применения
+func applyFromStaging(tx *sql.Tx, lessonFiles []lessonFile) error {
+	if _, err := tx.Exec("SET LOCAL lock_timeout = '" + applyLockTimeout + "'"); err != nil {
+		return err
+	}
+	for i := range lessonFiles {
+		lessonFiles[i].Source = stagingSource{tx: tx, ref: lessonFiles[i].Path}
+	}
+	return nil
+}
+
+// contentVersion - текущая версия контента (content_version, см. scripts/init.sql)
+func contentVersion(ctx context.Context, conn *sql.Conn) (int64, error) {
+	var version int64
+	err := conn.QueryRowContext(ctx, "SELECT version FROM content_version WHERE id = 1").Scan(&version)
+	return version, err
+}
@@ -0,0 +1,133 @@
+-- Служебные таблицы админ-панели go-admin (/admin, internal/admin).
+-- Схема повторяет data/admin.pgsql из go-admin, но без демо-пользователей admin/operator:
+-- учетные записи панели создаются автоматически для администраторов приложения при входе.
+-- Скрипт можно запускать повторно; в уже развернутой БД его нужно выполнить вручную
+-- (docker-entrypoint-initdb.d срабатывает только на пустом томе).
+
+CREATE TABLE IF NOT EXISTS goadmin_menu (
+    id SERIAL PRIMARY KEY,
+    parent_id INT DEFAULT 0 NOT NULL,
+    type INT DEFAULT 0,
+    "order" INT DEFAULT 0 NOT NULL,
+    title VARCHAR(50) NOT NULL,
+    header VARCHAR(100),
+    plugin_name VARCHAR(100) DEFAULT '' NOT NULL,
+    icon VARCHAR(50) NOT NULL,
+    uri VARCHAR(3000) NOT NULL,
+    uuid VARCHAR(100),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS goadmin_operation_log (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    path VARCHAR(255) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    ip VARCHAR(15) NOT NULL,
+    input TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS goadmin_site (
+    id SERIAL PRIMARY KEY,
+    key VARCHAR(100) NOT NULL,
+    value TEXT NOT NULL,
+    type INT DEFAULT 0,
+    description VARCHAR(3000),
+    state INT DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS goadmin_permissions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    slug VARCHAR(50) NOT NULL,
+    http_method VARCHAR(255),
+    http_path TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS goadmin_roles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR NOT NULL,
+    slug VARCHAR NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS goadmin_role_menu (
+    role_id INT NOT NULL,
+    menu_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS goadmin_role_permissions (
+    role_id INT NOT NULL,
+    permission_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS goadmin_role_users (
+    role_id INT NOT NULL,
+    user_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS goadmin_session (
+    id SERIAL PRIMARY KEY,
+    sid VARCHAR(50) NOT NULL,
+    "values" VARCHAR(3000) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS goadmin_user_permissions (
+    user_id INT NOT NULL,
+    permission_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- username - ID пользователя приложения (users.id), name - его email
+CREATE TABLE IF NOT EXISTS goadmin_users (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(100) NOT NULL UNIQUE,
+    password VARCHAR(100) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    avatar VARCHAR(255),
+    remember_token VARCHAR(100),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Одна роль панели со всеми правами: кого пускать, решает роль "admin" в users
+INSERT INTO goadmin_roles (id, name, slug) VALUES (1, 'Administrator', 'administrator') ON CONFLICT (id) DO NOTHING;
+INSERT INTO goadmin_permissions (id, name, slug, http_method, http_path) VALUES (1, 'All permission', '*', '', '*') ON CONFLICT (id) DO NOTHING;
+INSERT INTO goadmin_role_permissions (role_id, permission_id)
+SELECT 1, 1 WHERE NOT EXISTS (SELECT 1 FROM goadmin_role_permissions WHERE role_id = 1 AND permission_id = 1);
+
+-- Меню панели: разделы соответствуют таблицам из internal/admin
+INSERT INTO goadmin_menu (id, parent_id, type, "order", title, icon, uri) VALUES
+    (1, 0, 1, 1, 'Dashboard', 'fa-bar-chart', '/'),
+    (2, 0, 1, 2, 'Users', 'fa-users', '/info/users'),
+    (3, 0, 1, 3, 'Levels', 'fa-signal', '/info/levels'),
+    (4, 0, 1, 4, 'Lessons', 'fa-book', '/info/lessons'),
+    (5, 0, 1, 5, 'Sentences', 'fa-comment', '/info/sentences'),
+    (6, 0, 1, 6, 'Progress', 'fa-line-chart', '/info/progress'),
+    (7, 0, 1, 3, 'Courses', 'fa-globe', '/info/courses')
+ON CONFLICT (id) DO NOTHING;
+INSERT INTO goadmin_role_menu (role_id, menu_id)
+SELECT 1, m.id FROM goadmin_menu m
+WHERE m.id BETWEEN 1 AND 7 AND NOT EXISTS (SELECT 1 FROM goadmin_role_menu rm WHERE rm.role_id = 1 AND rm.menu_id = m.id);
+
+-- После вставки с явными ID сдвигаем последовательности
+SELECT setval(pg_get_serial_sequence('goadmin_roles', 'id'), GREATEST((SELECT MAX(id) FROM goadmin_roles), 1));
+SELECT setval(pg_get_serial_sequence('goadmin_permissions', 'id'), GREATEST((SELECT MAX(id) FROM goadmin_permissions), 1));
+SELECT setval(pg_get_serial_sequence('goadmin_menu', 'id'), GREATEST((SELECT MAX(id) FROM goadmin_menu), 1));
@@ -1,7 +1,7 @@
 -- Создаем таблицы только если они еще не существуют
 CREATE TABLE IF NOT EXISTS levels (
     id SERIAL PRIMARY KEY,
-    title VARCHAR(50) NOT NULL UNIQUE
+    title VARCHAR(50) NOT NULL
 );
 
 CREATE TABLE IF NOT EXISTS lessons (
@@ -11,35 +11,176 @@ CREATE TABLE IF NOT EXISTS lessons (
     title VARCHAR(255)
 );
 
+-- Публикация уроков: ученикам видны только 'published' с наступившим publish_at (NULL - сразу).
+-- Уже существующие уроки становятся опубликованными, новые по умолчанию - черновики.
+ALTER TABLE lessons ADD COLUMN IF NOT EXISTS status VARCHAR(20) DEFAULT 'published' NOT NULL
+    CHECK (status IN ('draft', 'review', 'published', 'archived'));
+ALTER TABLE lessons ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE lessons ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP WITH TIME ZONE;
+
+-- Метаданные курса из манифеста загрузчика (scripts/data_loader, -manifest)
+ALTER TABLE levels ADD COLUMN IF NOT EXISTS sort_order INT DEFAULT 0 NOT NULL; -- порядок показа; при равном - по названию
+ALTER TABLE levels ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE lessons ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE lessons ADD COLUMN IF NOT EXISTS grammar_topics JSONB DEFAULT '[]' NOT NULL; -- массив строк
+
+-- Курсы - языковые пары (ru-en, uk-en, ru-de). Уровни принадлежат курсу; у предложений prompt -
+-- на языке ученика (source_language), answer - на изучаемом языке (target_language)
+CREATE TABLE IF NOT EXISTS courses (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(32) NOT NULL UNIQUE, -- "ru-en": по нему курс указывают загрузчик и манифест
+    title VARCHAR(100) NOT NULL,
+    source_language VARCHAR(3) NOT NULL, -- ISO 639, как в user_profiles
+    target_language VARCHAR(3) NOT NULL CHECK (target_language <> source_language),
+    tts_language VARCHAR(10) NOT NULL, -- язык озвучки ответов: "en-US"
+    tts_voice VARCHAR(64) NOT NULL,    -- голос Google TTS (scripts/audio_generator): "en-US-Standard-F"
+    sort_order INT DEFAULT 0 NOT NULL, -- первый по порядку - курс по умолчанию для новых учеников
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+-- Весь контент, загруженный до появления курсов, - русско-английский
+INSERT INTO courses (code, title, source_language, target_language, tts_language, tts_voice)
+VALUES ('ru-en', 'Русский → English', 'ru', 'en', 'en-US', 'en-US-Standard-F')
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE levels ADD COLUMN IF NOT EXISTS course_id INT REFERENCES courses(id);
+UPDATE levels SET course_id = (SELECT id FROM courses WHERE code = 'ru-en') WHERE course_id IS NULL;
+ALTER TABLE levels ALTER COLUMN course_id SET NOT NULL;
+-- Название уровня уникально в своем курсе: A1 есть и в ru-en, и в ru-de
+ALTER TABLE levels DROP CONSTRAINT IF EXISTS levels_title_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_levels_course_title ON levels (course_id, title);
+
 CREATE TABLE IF NOT EXISTS sentences (
     id SERIAL PRIMARY KEY,
     lesson_id INT NOT NULL REFERENCES lessons(id),
     order_number INT NOT NULL, -- Порядковый номер в уроке (1, 2, 3...)
-    prompt_ru TEXT NOT NULL,
-    answer_en TEXT NOT NULL,
+    prompt TEXT NOT NULL, -- на языке ученика (courses.source_language)
+    answer TEXT NOT NULL, -- на изучаемом языке (courses.target_language)
     transcription TEXT,
     audio_path VARCHAR(1024),
     
     UNIQUE (lesson_id, order_number)
 );
 
+-- До появления курсов поля назывались по языкам: prompt_ru, answer_en
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'sentences' AND column_name = 'prompt_ru') THEN
+        ALTER TABLE sentences RENAME COLUMN prompt_ru TO prompt;
+        ALTER TABLE sentences RENAME COLUMN answer_en TO answer;
+    END IF;
+END $$;
+
+-- Хеш содержимого (internal/content.Hash: sha256 от prompt, answer, transcription через \x1f)
+ALTER TABLE sentences ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64);
+UPDATE sentences SET content_hash = encode(sha256(convert_to(
+    prompt || chr(31) || answer || chr(31) || COALESCE(transcription, ''), 'UTF8')), 'hex')
+WHERE content_hash IS NULL;
+
+-- Постоянный ключ предложения из первого столбца CSV (sentence_id): загрузчик сопоставляет по нему,
+-- а order_number берет из порядка строк. Старые данные грузились по месту в файле, где sentence_id
+-- совпадал с номером, поэтому для уроков без ключей он восстанавливается из order_number.
+ALTER TABLE sentences ADD COLUMN IF NOT EXISTS external_id VARCHAR(64);
+UPDATE sentences SET external_id = order_number::text
+WHERE external_id IS NULL AND NOT EXISTS (
+    SELECT 1 FROM sentences keyed WHERE keyed.lesson_id = sentences.lesson_id AND keyed.external_id IS NOT NULL);
+-- Предложениям, добавленным в редакторе в уроки с ключами, - следующие свободные числовые ключи урока
+-- (их выгружает scripts/data_loader export, и без ключа следующая загрузка убрала бы их в архив)
+UPDATE sentences s SET external_id = (k.base + k.rn)::text
+FROM (
+    SELECT x.id, ROW_NUMBER() OVER (PARTITION BY x.lesson_id ORDER BY x.order_number) AS rn,
+        (SELECT COALESCE(MAX(n.external_id::int), 0) FROM sentences n
+         WHERE n.lesson_id = x.lesson_id AND n.external_id ~ '^[0-9]{1,9}$') AS base
+    FROM sentences x WHERE x.external_id IS NULL
+) k
+WHERE s.id = k.id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sentences_external_id ON sentences (lesson_id, external_id);
+
+-- Предложения, пропавшие из CSV, не удаляются (на них ссылается прогресс), а уходят в архив:
+-- archived_at проставлен, order_number = -(id + 1000000000), ученикам не показываются.
+-- Небольшие отрицательные номера заняты перестановками (загрузчик временно ставит -id,
+-- редактор - -order_number), поэтому архив вынесен в свой диапазон, где они его не задевают.
+ALTER TABLE sentences ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE;
+
 CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
-    email VARCHAR(255) NOT NULL UNIQUE,
+    email VARCHAR(255) UNIQUE, -- NULL только у гостей
     password_hash VARCHAR(255) NOT NULL,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     subscription_status VARCHAR(20) DEFAULT 'free' NOT NULL, -- 'free', 'premium'
     stripe_customer_id VARCHAR(255) UNIQUE,
 
     -- ▼▼▼ ДОБАВЛЕНЫ ДЛЯ ТОЧНОСТИ ▼▼▼
-    role VARCHAR(20) DEFAULT 'user' NOT NULL,
+    role VARCHAR(20) DEFAULT 'user' NOT NULL
+        CHECK (role IN ('guest', 'user', 'teacher', 'content_editor', 'admin')),
     total_attempts INT DEFAULT 0,
-    total_correct INT DEFAULT 0
+    total_correct INT DEFAULT 0,
+
+    email_verified_at TIMESTAMP WITH TIME ZONE, -- NULL = email не подтвержден
+
+    -- Двухфакторная аутентификация (TOTP)
+    totp_secret VARCHAR(64),                  -- base32; есть, но totp_enabled_at NULL = настройка не завершена
+    totp_enabled_at TIMESTAMP WITH TIME ZONE,
+    totp_last_step BIGINT,                     -- последний принятый шаг, чтобы код нельзя было использовать дважды
+
+    -- FALSE - пароля нет (аккаунт создан через OIDC): личность подтверждают кодом 2FA или недавним входом
+    has_password BOOLEAN NOT NULL DEFAULT TRUE,
+
+    -- Удаление аккаунта: сначала "мягкое", окончательно - после purge_after
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    purge_after TIMESTAMP WITH TIME ZONE
+);
+
+-- Для уже развернутых БД: гости (role = 'guest') живут без email
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check
+    CHECK (role IN ('guest', 'user', 'teacher', 'content_editor', 'admin'));
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_required;
+ALTER TABLE users ADD CONSTRAINT users_email_required CHECK (email IS NOT NULL OR role = 'guest');
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS purge_after TIMESTAMP WITH TIME ZONE;
+
+-- Email уникален без учета регистра (Ivan@Mail.ru и ivan@mail.ru - один аккаунт).
+-- В старой БД такие дубли могут уже быть: объединять аккаунты за людей скрипт не берется,
+-- поэтому перечисляет их и не создает индекс, пока дубли не разобраны вручную (затем init.sql
+-- запускается еще раз).
+DO $$
+DECLARE
+    dup RECORD;
+    found BOOLEAN := FALSE;
+BEGIN
+    FOR dup IN
+        SELECT LOWER(email) AS email, string_agg(id::text, ', ' ORDER BY id) AS ids
+        FROM users WHERE email IS NOT NULL
+        GROUP BY LOWER(email) HAVING COUNT(*) > 1
+    LOOP
+        found := TRUE;
+        RAISE WARNING 'email % встречается у пользователей %', dup.email, dup.ids;
+    END LOOP;
+    IF found THEN
+        RAISE WARNING 'idx_users_email_lower не создан: есть email, отличающиеся только регистром';
+    ELSE
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email));
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 от токена из ссылки, сам токен не храним
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
 CREATE TABLE IF NOT EXISTS user_progress (
     id SERIAL PRIMARY KEY,
-    user_id INT NOT NULL REFERENCES users(id),
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- удаление аккаунта чистит прогресс
     sentence_id INT NOT NULL REFERENCES sentences(id),
     status VARCHAR(20) DEFAULT 'new' NOT NULL, -- 'new', 'learning', 'mastered'
     correct_streak INT DEFAULT 0 NOT NULL,
@@ -47,18 +188,279 @@ CREATE TABLE IF NOT EXISTS user_progress (
     
     -- ▼▼▼ ДОБАВЛЕНО ЭТО ПОЛЕ ▼▼▼
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    mistake_count INT DEFAULT 0 NOT NULL, -- сколько раз ошибся (для звезд)
     
     UNIQUE(user_id, sentence_id)
 );
 
+-- Для уже развернутых БД: раньше у внешнего ключа не было ON DELETE
+ALTER TABLE user_progress DROP CONSTRAINT IF EXISTS user_progress_user_id_fkey;
+ALTER TABLE user_progress ADD CONSTRAINT user_progress_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- История попыток (каждый ответ в SaveProgress), нужна для экспорта данных
+CREATE TABLE IF NOT EXISTS user_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    sentence_id INT NOT NULL REFERENCES sentences(id),
+    is_correct BOOLEAN NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_user_attempts_user ON user_attempts (user_id, created_at);
+
+-- Сессии: ID сессии лежит в JWT (claim "jti"), отозванная сессия больше не пускает в API
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    ip VARCHAR(64),
+    user_agent VARCHAR(255),
+    impersonator_id INT REFERENCES users(id) ON DELETE SET NULL -- сессия администратора "под" пользователем
+);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions (user_id);
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS impersonator_id INT REFERENCES users(id) ON DELETE SET NULL;
+
+-- Запросы на смену email: адрес меняется только после перехода по ссылке из письма
+CREATE TABLE IF NOT EXISTS email_change_requests (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Настройки профиля (одна строка на пользователя, создается при первом PATCH /api/me)
+CREATE TABLE IF NOT EXISTS user_profiles (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    display_name VARCHAR(50) DEFAULT '' NOT NULL,
+    native_language VARCHAR(3) DEFAULT 'ru' NOT NULL,
+    target_language VARCHAR(3) DEFAULT 'en' NOT NULL,
+    time_zone VARCHAR(64) DEFAULT 'UTC' NOT NULL,
+    daily_goal INT DEFAULT 20 NOT NULL CHECK (daily_goal BETWEEN 1 AND 500),
+    preferred_tts_voice VARCHAR(64) DEFAULT 'en-US-Standard-F' NOT NULL,
+    ui_locale VARCHAR(10) DEFAULT 'ru' NOT NULL,
+    notification_prefs JSONB DEFAULT '{}' NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Курс, который ученик сейчас проходит; NULL - курс по умолчанию (первый по courses.sort_order)
+ALTER TABLE user_profiles ADD COLUMN IF NOT EXISTS active_course_id INT REFERENCES courses(id) ON DELETE SET NULL;
+
+-- Внешние личности (OIDC: Google, Яндекс, ...), привязанные к аккаунтам
+CREATE TABLE IF NOT EXISTS user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,  -- имя из OIDC_PROVIDERS
+    subject VARCHAR(255) NOT NULL,  -- claim "sub" у провайдера
+    email VARCHAR(255),             -- email на момент привязки
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (provider, subject)
+);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities (user_id);
+
+-- Для уже развернутых БД: аккаунты, созданные через OIDC (личность привязана в момент регистрации),
+-- пароля не имеют. Заполняется один раз, при добавлении столбца
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'has_password') THEN
+        ALTER TABLE users ADD COLUMN has_password BOOLEAN NOT NULL DEFAULT TRUE;
+        UPDATE users u SET has_password = FALSE FROM user_identities i
+        WHERE i.user_id = u.id AND i.created_at < u.created_at + INTERVAL '1 minute';
+    END IF;
+END $$;
+
+-- Незавершенные OIDC-входы (state хранится в виде SHA-256)
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+    state CHAR(64) PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Одноразовые коды восстановления 2FA (храним только SHA-256)
+CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (user_id, code_hash)
+);
+
+-- Счетчики неудачных попыток входа/регистрации по ключу "email:..." или "ip:..."
+CREATE TABLE IF NOT EXISTS auth_throttle (
+    action VARCHAR(32) NOT NULL, -- 'login', 'register', 'password_reset', ...
+    key VARCHAR(320) NOT NULL,
+    failures INT DEFAULT 0 NOT NULL,
+    last_failure_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (action, key)
+);
+
+-- История блокировок (только добавление)
+CREATE TABLE IF NOT EXISTS auth_lockouts (
+    id SERIAL PRIMARY KEY,
+    action VARCHAR(32) NOT NULL,
+    key VARCHAR(320) NOT NULL,
+    failures INT NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    ip VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Персональные токены доступа (PAT) для скриптов и интеграций; сам токен не храним, только SHA-256
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    token_prefix VARCHAR(16) NOT NULL,     -- начало токена, чтобы узнать его в списке
+    scopes VARCHAR(255) NOT NULL,          -- через запятую: read-content,read-progress,write-progress
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user ON personal_access_tokens (user_id);
+
+-- Журнал событий безопасности и администрирования (internal/audit). Только добавление:
+-- UPDATE и DELETE запрещены триггером. actor_id/target_id без FK - журнал переживает удаление аккаунтов.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    event_type VARCHAR(64) NOT NULL,       -- 'auth.login', 'user.role_changed', ...
+    actor_id INT,                          -- кто сделал; NULL - аноним или система
+    impersonator_id INT,                   -- администратор, если действие шло под чужим аккаунтом
+    target_type VARCHAR(32),               -- 'user', 'token', 'lesson', ...
+    target_id VARCHAR(64),
+    ip VARCHAR(64),
+    user_agent VARCHAR(255),
+    data JSONB
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target_type, target_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events (event_type, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created ON audit_events (created_at);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_no_modify ON audit_events;
+CREATE TRIGGER audit_events_no_modify BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+-- История правок предложений: кто/что поменял и что стало с прогрессом учеников
+CREATE TABLE IF NOT EXISTS sentence_revisions (
+    id SERIAL PRIMARY KEY,
+    sentence_id INT NOT NULL REFERENCES sentences(id) ON DELETE CASCADE,
+    revision INT NOT NULL, -- 1, 2, 3... в пределах предложения
+    prompt TEXT NOT NULL,
+    answer TEXT NOT NULL,
+    transcription TEXT,
+    content_hash VARCHAR(64) NOT NULL,
+    changed_by INT REFERENCES users(id) ON DELETE SET NULL, -- NULL - импорт или система
+    source VARCHAR(20) NOT NULL, -- 'editor', 'data_loader', 'baseline'
+    source_ref TEXT,             -- например, путь к CSV
+    progress_policy VARCHAR(20)  -- 'keep', 'downgrade', 'reset'; NULL - ответ по существу не менялся
+        CHECK (progress_policy IN ('keep', 'downgrade', 'reset')),
+    affected_progress INT DEFAULT 0 NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (sentence_id, revision)
+);
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'sentence_revisions' AND column_name = 'prompt_ru') THEN
+        ALTER TABLE sentence_revisions RENAME COLUMN prompt_ru TO prompt;
+        ALTER TABLE sentence_revisions RENAME COLUMN answer_en TO answer;
+    END IF;
+END $$;
+
+-- Для уже загруженных предложений история начинается с текущей версии
+INSERT INTO sentence_revisions (sentence_id, revision, prompt, answer, transcription, content_hash, source)
+SELECT s.id, 1, s.prompt, s.answer, s.transcription, s.content_hash, 'baseline'
+FROM sentences s
+WHERE NOT EXISTS (SELECT 1 FROM sentence_revisions r WHERE r.sentence_id = s.id);
+
+-- Из чего загружен урок (scripts/data_loader): контрольная сумма строк источника и отпечаток
+-- предложений в БД после загрузки. Если оба совпадают, следующий запуск урок пропускает
+CREATE TABLE IF NOT EXISTS lesson_sources (
+    lesson_id INT PRIMARY KEY REFERENCES lessons(id) ON DELETE CASCADE,
+    source_ref TEXT NOT NULL, -- файл или "книга.xlsx#лист"
+    checksum VARCHAR(64) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    loaded_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Версия контента (GET /api/content/version): растет на 1 за каждую транзакцию, которая поменяла
+-- курсы, уровни, уроки или предложения - загрузку, правку в админке или через API. Клиенты по ней
+-- понимают, что кэш уроков пора обновить. Одна строка; updated_tx - транзакция последнего увеличения.
+-- Урок с наступившим publish_at появляется без изменения строк, поэтому такие выпуски учитывает
+-- сам GET /api/content/version (internal/api/version.go): released_until - до какого момента они учтены
+CREATE TABLE IF NOT EXISTS content_version (
+    id INT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    version BIGINT NOT NULL DEFAULT 1,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_tx BIGINT,
+    released_until TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE content_version ADD COLUMN IF NOT EXISTS released_until TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+INSERT INTO content_version (id) VALUES (1) ON CONFLICT (id) DO NOTHING;
+
+CREATE OR REPLACE FUNCTION bump_content_version() RETURNS trigger AS $$
+BEGIN
+    UPDATE content_version SET version = version + 1, updated_at = NOW(), updated_tx = txid_current()
+    WHERE id = 1 AND updated_tx IS DISTINCT FROM txid_current();
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- UPDATE без фактических изменений (загрузчик сверяет и неизменившиеся строки) версию не трогает
+DROP TRIGGER IF EXISTS courses_content_version ON courses;
+CREATE TRIGGER courses_content_version AFTER INSERT OR DELETE ON courses
+    FOR EACH ROW EXECUTE FUNCTION bump_content_version();
+DROP TRIGGER IF EXISTS courses_content_version_update ON courses;
+CREATE TRIGGER courses_content_version_update AFTER UPDATE ON courses
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION bump_content_version();
+DROP TRIGGER IF EXISTS levels_content_version ON levels;
+CREATE TRIGGER levels_content_version AFTER INSERT OR DELETE ON levels
+    FOR EACH ROW EXECUTE FUNCTION bump_content_version();
+DROP TRIGGER IF EXISTS levels_content_version_update ON levels;
+CREATE TRIGGER levels_content_version_update AFTER UPDATE ON levels
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION bump_content_version();
+DROP TRIGGER IF EXISTS lessons_content_version ON lessons;
+CREATE TRIGGER lessons_content_version AFTER INSERT OR DELETE ON lessons
+    FOR EACH ROW EXECUTE FUNCTION bump_content_version();
+DROP TRIGGER IF EXISTS lessons_content_version_update ON lessons;
+CREATE TRIGGER lessons_content_version_update AFTER UPDATE ON lessons
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION bump_content_version();
+DROP TRIGGER IF EXISTS sentences_content_version ON sentences;
+CREATE TRIGGER sentences_content_version AFTER INSERT OR DELETE ON sentences
+    FOR EACH ROW EXECUTE FUNCTION bump_content_version();
+DROP TRIGGER IF EXISTS sentences_content_version_update ON sentences;
+CREATE TRIGGER sentences_content_version_update AFTER UPDATE ON sentences
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION bump_content_version();
+
 -- Индексы для ускорения запросов
 CREATE INDEX IF NOT EXISTS idx_user_progress_review ON user_progress (user_id, next_review_date);
 CREATE INDEX IF NOT EXISTS idx_sentences_lesson ON sentences (lesson_id);
+-- Номер урока уникален в уровне: на это рассчитывают загрузчик и админские эндпоинты
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lessons_level_number ON lessons (level_id, lesson_number);
 
 -- === НОВОЕ: Добавляем все уровни ===
-INSERT INTO levels (title) VALUES ('A0') ON CONFLICT (title) DO NOTHING;
-INSERT INTO levels (title) VALUES ('A1') ON CONFLICT (title) DO NOTHING;
-INSERT INTO levels (title) VALUES ('A2') ON CONFLICT (title) DO NOTHING;
-INSERT INTO levels (title) VALUES ('B1') ON CONFLICT (title) DO NOTHING;
-INSERT INTO levels (title) VALUES ('B2') ON CONFLICT (title) DO NOTHING;
-INSERT INTO levels (title) VALUES ('C1') ON CONFLICT (title) DO NOTHING;
\ No newline at end of file
+INSERT INTO levels (course_id, title) SELECT id, 'A0' FROM courses WHERE code = 'ru-en' ON CONFLICT (course_id, title) DO NOTHING;
+INSERT INTO levels (course_id, title) SELECT id, 'A1' FROM courses WHERE code = 'ru-en' ON CONFLICT (course_id, title) DO NOTHING;
+INSERT INTO levels (course_id, title) SELECT id, 'A2' FROM courses WHERE code = 'ru-en' ON CONFLICT (course_id, title) DO NOTHING;
+INSERT INTO levels (course_id, title) SELECT id, 'B1' FROM courses WHERE code = 'ru-en' ON CONFLICT (course_id, title) DO NOTHING;
+INSERT INTO levels (course_id, title) SELECT id, 'B2' FROM courses WHERE code = 'ru-en' ON CONFLICT (course_id, title) DO NOTHING;
+INSERT INTO levels (course_id, title) SELECT id, 'C1' FROM courses WHERE code = 'ru-en' ON CONFLICT (course_id, title) DO NOTHING;
\ No newline at end of file
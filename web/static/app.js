@@ -13,6 +13,7 @@ document.addEventListener("DOMContentLoaded", () => {
         userEmail: localStorage.getItem("userEmail") || null,
         currentView: 'dashboard',
         currentLessonId: null,
+        course: null, // активный курс: языки и голос озвучки
         levels: [],
         lessons: [],
         sentences: [],
@@ -113,6 +114,7 @@ document.addEventListener("DOMContentLoaded", () => {
             
             const data = await response.json(); 
             state.levels = data.levels; 
+            state.course = data.course || null;
 
             const completed = data.completed_lessons || 0;
             const total = data.total_lessons || 0;
@@ -244,9 +246,8 @@ document.addEventListener("DOMContentLoaded", () => {
             const response = await fetchProtected("/api/ai/explain-error", {
                 method: "POST",
                 body: JSON.stringify({
-                    prompt_ru: sentence.prompt_ru,
-                    correct_en: sentence.answer_en,
-                    user_answer_en: userAns
+                    sentence_id: sentence.id,
+                    user_answer: userAns
                 })
             });
             if (!response) return;
@@ -386,7 +387,7 @@ document.addEventListener("DOMContentLoaded", () => {
              return;
         }
         const sentence = state.sentences[state.currentSentenceIndex];
-        if (dom.promptRu) dom.promptRu.textContent = sentence.prompt_ru;
+        if (dom.promptRu) dom.promptRu.textContent = sentence.prompt;
         if (dom.userAnswer) {
             dom.userAnswer.value = "";
             dom.userAnswer.disabled = false;
@@ -408,7 +409,7 @@ document.addEventListener("DOMContentLoaded", () => {
     function handleCheckAnswer() {
         const sentence = state.sentences[state.currentSentenceIndex];
         const userAns = dom.userAnswer.value.trim();
-        const correctAns = sentence.answer_en.trim();
+        const correctAns = sentence.answer.trim();
         const isCorrect = userAns.toLowerCase().replace(/[.,!?]/g, '') === correctAns.toLowerCase().replace(/[.,!?]/g, '');
         
         if (isCorrect) state.sentences[state.currentSentenceIndex].status = { String: 'mastered', Valid: true };
@@ -458,16 +459,16 @@ document.addEventListener("DOMContentLoaded", () => {
         if (!sentence) return;
         if (sentence.audio_path && sentence.audio_path.Valid) {
             const audio = new Audio(sentence.audio_path.String);
-            audio.play().catch(() => playBrowserTTS(sentence.answer_en));
+            audio.play().catch(() => playBrowserTTS(sentence.answer));
         } else {
-            playBrowserTTS(sentence.answer_en);
+            playBrowserTTS(sentence.answer);
         }
     }
     
     function playBrowserTTS(text) {
         if(!text) return;
         const u = new SpeechSynthesisUtterance(text);
-        u.lang = "en-US";
+        u.lang = (state.course && state.course.tts_language) || "en-US";
         window.speechSynthesis.speak(u);
     }
 
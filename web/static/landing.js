@@ -11,6 +11,7 @@ document.addEventListener("DOMContentLoaded", () => {
         headerLoginBtn: document.getElementById("landing-login-button"),
         heroStartBtn: document.getElementById("landing-start-button"),
         ctaStartBtn: document.getElementById("landing-cta-start-button"),
+        guestBtn: document.getElementById("landing-guest-button"),
         
         // Модалка
         overlay: document.getElementById("auth-overlay"),
@@ -74,15 +75,11 @@ document.addEventListener("DOMContentLoaded", () => {
             const data = await response.json();
             if (!response.ok) throw new Error(data.error || "Ошибка входа");
 
-            // Сохраняем токен и email
-            localStorage.setItem("token", data.token);
-            localStorage.setItem("userEmail", email);
-            
-            // Устанавливаем cookie для Go-сервера
-            document.cookie = "auth_status=logged_in; path=/; max-age=" + 60*60*24*3; 
-
-            // Перенаправляем в приложение
-            window.location.href = "/app"; 
+            if (data.mfa_required) {
+                await completeTwoFactorLogin(data.challenge_token, email);
+                return;
+            }
+            await finishLogin(data.token, email);
 
         } catch (error) {
             if (dom.loginError) {
@@ -92,6 +89,48 @@ document.addEventListener("DOMContentLoaded", () => {
         }
     }
 
+    // Второй шаг входа: код из приложения-аутентификатора или код восстановления
+    async function completeTwoFactorLogin(challengeToken, email) {
+        const code = prompt("Введите код из приложения-аутентификатора или код восстановления:");
+        if (!code) throw new Error("Вход отменен");
+
+        const response = await fetch("/api/login/2fa", {
+            method: "POST",
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ challenge_token: challengeToken, code: code.trim() })
+        });
+        const data = await response.json();
+        if (!response.ok) throw new Error(data.error || "Неверный код");
+        await finishLogin(data.token, email);
+    }
+
+    async function finishLogin(token, email) {
+        // Прогресс, набранный в гостевом режиме, переносим в аккаунт
+        const guestToken = localStorage.getItem("guestToken");
+        if (guestToken) {
+            try {
+                await fetch("/api/me/merge-guest", {
+                    method: "POST",
+                    headers: { 'Content-Type': 'application/json', 'Authorization': `Bearer ${token}` },
+                    body: JSON.stringify({ guest_token: guestToken })
+                });
+            } catch (error) {
+                console.error("Error merging guest progress:", error);
+            }
+            localStorage.removeItem("guestToken");
+        }
+
+        // Сохраняем токен и email
+        localStorage.setItem("token", token);
+        localStorage.setItem("userEmail", email);
+
+        // Устанавливаем cookie для Go-сервера
+        document.cookie = "auth_status=logged_in; path=/; max-age=" + 60*60*24*3;
+
+        // Перенаправляем в приложение
+        window.location.href = "/app";
+    }
+
     async function handleRegister(e) {
         e.preventDefault();
         if (dom.registerError) dom.registerError.style.display = "none";
@@ -111,13 +150,14 @@ document.addEventListener("DOMContentLoaded", () => {
             const response = await fetch("/api/register", {
                 method: "POST",
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ email, password })
+                body: JSON.stringify({ email, password, guest_token: localStorage.getItem("guestToken") || undefined })
             });
             const data = await response.json();
             if (!response.ok) throw new Error(data.error || "Ошибка регистрации");
+            localStorage.removeItem("guestToken");
 
             // Успех
-            alert("Регистрация успешна! Теперь войдите.");
+            alert("Регистрация успешна! Мы отправили письмо для подтверждения email. Теперь войдите.");
             showAuthForm('login');
 
         } catch (error) {
@@ -128,6 +168,33 @@ document.addEventListener("DOMContentLoaded", () => {
         }
     }
 
+    // Гостевой режим: бесплатные уроки без регистрации. Токен гостя храним отдельно,
+    // чтобы после входа перенести его прогресс в аккаунт (см. finishLogin).
+    async function startGuest(e) {
+        e.preventDefault();
+        try {
+            let guestToken = localStorage.getItem("guestToken");
+            if (guestToken) {
+                // Гость мог истечь (30 дней без входа) - тогда начинаем заново
+                const check = await fetch("/api/levels", { headers: { 'Authorization': `Bearer ${guestToken}` } });
+                if (check.status === 401) guestToken = null;
+            }
+            if (!guestToken) {
+                const response = await fetch("/api/guest", { method: "POST" });
+                const data = await response.json();
+                if (!response.ok) throw new Error(data.error || "Не удалось начать гостевой режим");
+                guestToken = data.token;
+                localStorage.setItem("guestToken", guestToken);
+            }
+            localStorage.setItem("token", guestToken);
+            localStorage.setItem("userEmail", "Гость");
+            document.cookie = "auth_status=logged_in; path=/; max-age=" + 60*60*24*30;
+            window.location.href = "/app";
+        } catch (error) {
+            alert(error.message);
+        }
+    }
+
     // === Привязка Событий ===
     
     // Открыть модалку (Вход)
@@ -152,6 +219,8 @@ document.addEventListener("DOMContentLoaded", () => {
         });
     }
 
+    if (dom.guestBtn) dom.guestBtn.addEventListener("click", startGuest);
+
     // Закрыть модалку
     if (dom.closeModalBtn) dom.closeModalBtn.addEventListener("click", closeAuthModal);
     if (dom.overlay) dom.overlay.addEventListener("click", closeAuthModal);
@@ -174,6 +243,56 @@ document.addEventListener("DOMContentLoaded", () => {
     if (dom.loginForm) dom.loginForm.addEventListener("submit", handleLogin);
     if (dom.registerForm) dom.registerForm.addEventListener("submit", handleRegister);
 
+    // Кнопки входа через внешних провайдеров (Google, Яндекс, ...)
+    async function loadAuthProviders() {
+        const container = document.getElementById("oauth-providers");
+        if (!container) return;
+        try {
+            const response = await fetch("/api/auth/providers");
+            if (!response.ok) return;
+            const providers = await response.json();
+            providers.forEach(p => {
+                const link = document.createElement("a");
+                link.href = p.start_url;
+                link.textContent = `Войти через ${p.display_name}`;
+                container.appendChild(link);
+            });
+        } catch (error) {
+            console.error("Error loading auth providers:", error);
+        }
+    }
+
+    // Возврат с внешнего провайдера: токен приходит во fragment'е (#token=...)
+    async function handleOAuthRedirect() {
+        if (!window.location.hash) return;
+        const params = new URLSearchParams(window.location.hash.slice(1));
+        const email = params.get("email") || "";
+        history.replaceState(null, "", window.location.pathname + window.location.search);
+
+        if (params.get("token")) {
+            finishLogin(params.get("token"), email);
+        } else if (params.get("mfa_challenge")) {
+            openAuthModal('login');
+            try {
+                await completeTwoFactorLogin(params.get("mfa_challenge"), email);
+            } catch (error) {
+                if (dom.loginError) {
+                    dom.loginError.textContent = error.message;
+                    dom.loginError.style.display = "block";
+                }
+            }
+        } else if (params.get("oidc_error")) {
+            openAuthModal('login');
+            if (dom.loginError) {
+                dom.loginError.textContent = "Не удалось войти через внешний сервис. Попробуйте еще раз или войдите по паролю.";
+                dom.loginError.style.display = "block";
+            }
+        }
+    }
+
+    loadAuthProviders();
+    handleOAuthRedirect();
+
     // Анимации лендинга (fade-up)
     const io = new IntersectionObserver((entries) => {
         entries.forEach(e => {